@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-openapi/spec"
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var configFile string
+
+// Returns the config file given by --config or found in the working
+// directory, blank if there is none
+func configPath() string {
+	if configFile != "" {
+		return configFile
+	}
+	return pkg.FindConfig()
+}
+
+// Loads the project config from --config, or from schemagen.yaml in the
+// working directory if there is one, and overrides the inputs with the
+// flags that are given on command line
+func loadConfig(cmd *cobra.Command) (*pkg.Config, error) {
+	cfg := pkg.DefaultConfig()
+	if filename := configPath(); filename != "" {
+		var err error
+		if cfg, err = pkg.LoadConfig(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("kedgespec") {
+		cfg.Kedge.Sources = []string{kedgeSpecLocation}
+	}
+	if flags.Changed("k8sSchema") {
+		cfg.Upstream.Kubernetes = kubernetesSchema
+	}
+	if flags.Changed("osSchema") {
+		cfg.Upstream.OpenShift = openshiftSchema
+	}
+
+	if !noCache {
+		cache, err := schemaCache()
+		if err != nil {
+			// the schemas are parsed every time instead
+			log.Debugln(err)
+		} else {
+			cfg.Cache = cache
+		}
+	}
+
+	// use the files fetched into cache instead
+	if locked {
+		lock, err := pkg.ReadLockFile(lockFile)
+		if err != nil {
+			return nil, err
+		}
+		source, err := lock.Resolve(pkg.UpstreamKedge, cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Kedge.Sources = []string{source}
+		if cfg.Upstream.Kubernetes, err = lock.Resolve(pkg.UpstreamKubernetes, cacheDir); err != nil {
+			return nil, err
+		}
+		if cfg.Upstream.OpenShift, err = lock.Resolve(pkg.UpstreamOpenShift, cacheDir); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Returns the cache of parsed upstream schemas, in --schema-cache-dir or
+// in the cache directory of the user
+func schemaCache() (*pkg.SchemaCache, error) {
+	if schemaCacheDir != "" {
+		return pkg.NewSchemaCache(schemaCacheDir), nil
+	}
+	dir, err := pkg.DefaultSchemaCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return pkg.NewSchemaCache(dir), nil
+}
+
+// Returns the definitions of the already generated schema file if given,
+// else the definitions are generated from the inputs in config
+func loadDefinitions(cmd *cobra.Command, schemaFile string) (spec.Definitions, error) {
+	if schemaFile != "" {
+		api, err := pkg.ParseSwagger(pkg.FileSource(schemaFile))
+		if err != nil {
+			return nil, err
+		}
+		return api.Definitions, nil
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err == nil {
+		err = cfg.ValidateInputs()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// all definitions are needed, whatever config says about pruning
+	opts := cfg.GeneratorOptions()
+	opts.Prune, opts.Roots = false, nil
+	g, err := pkg.NewGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+	api, err := g.Generate()
+	if err != nil {
+		return nil, err
+	}
+	return api.Definitions, nil
+}
+
+// Writes the diagnostics as SARIF to the given file
+func writeSARIFFile(filename string, diags []pkg.Diagnostic) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := pkg.WriteSARIF(f, diags); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var diffFormat string
+
+// diffCmd compares two generated OpenAPI schemas
+var diffCmd = &cobra.Command{
+	Use:   "diff old.json new.json",
+	Short: "Compare two generated OpenAPI schemas and report breaking changes.",
+	Long: `Compare definitions of two generated OpenAPI schemas structurally.
+
+Changes are sorted into breaking ones, which can make previously valid
+Kedge files invalid, and non-breaking ones. Exits with 1 if any breaking
+change is found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			fmt.Println("diff needs exactly two arguments, old and new schema files")
+			os.Exit(-1)
+		}
+
+		d, err := diff(args[0], args[1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+
+		switch diffFormat {
+		case "text":
+			err = pkg.WriteDiffText(os.Stdout, d)
+		case pkg.FormatJSON:
+			err = pkg.WriteDiffJSON(os.Stdout, d)
+		case pkg.FormatJSONCompact, pkg.FormatYAML:
+			err = pkg.Write(os.Stdout, d, diffFormat)
+		default:
+			err = fmt.Errorf("unknown output format %q", diffFormat)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+
+		if d.HasBreaking() {
+			os.Exit(1)
+		}
+	},
+}
+
+func diff(oldSchema, newSchema string) (*pkg.SchemaDiff, error) {
+	oldApi, err := pkg.ParseSwagger(pkg.FileSource(oldSchema))
+	if err != nil {
+		return nil, fmt.Errorf("old: %v", err)
+	}
+	newApi, err := pkg.ParseSwagger(pkg.FileSource(newSchema))
+	if err != nil {
+		return nil, fmt.Errorf("new: %v", err)
+	}
+	return pkg.DiffDefinitions(oldApi.Definitions, newApi.Definitions), nil
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "text", "Output format, one of: text, json, json-compact, yaml")
+	RootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	docsSchema     string
+	docsFormat     string
+	docsTitle      string
+	docsOutputFile string
+)
+
+// docsCmd renders reference docs of Kedge file format
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate reference docs of Kedge file format as Markdown or HTML.",
+	Long: `Generate reference docs of Kedge file format from the generated definitions.
+
+Every io.kedge.* definition gets its own section with a table of its fields,
+their types, whether they are required, their defaults and descriptions.
+Upstream Kubernetes and OpenShift types that are used by Kedge get sections
+too and fields link to the sections of their types. Anchors are made from
+the definition keys so links to them stay the same between runs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := docs(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func docs(cmd *cobra.Command) error {
+	if docsFormat != pkg.DocsMarkdown && docsFormat != pkg.DocsHTML {
+		return fmt.Errorf("unknown docs format %q", docsFormat)
+	}
+	defs, err := loadDefinitions(cmd, docsSchema)
+	if err != nil {
+		return err
+	}
+	d, err := pkg.BuildDocs(defs, docsTitle)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if docsOutputFile != "" {
+		f, err := os.Create(docsOutputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return pkg.WriteDocs(w, d, docsFormat)
+}
+
+func init() {
+	addGenerationFlags(docsCmd)
+	docsCmd.Flags().StringVar(&docsSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	docsCmd.Flags().StringVar(&docsFormat, "format", pkg.DocsMarkdown, "Format of the docs, one of: markdown, html")
+	docsCmd.Flags().StringVar(&docsTitle, "title", "Kedge file reference", "Title of the docs")
+	docsCmd.Flags().StringVar(&docsOutputFile, "output-file", "", "File to write the docs to instead of standard output")
+	RootCmd.AddCommand(docsCmd)
+}
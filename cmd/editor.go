@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	editorURL         string
+	editorName        string
+	editorDescription string
+	editorFileMatch   []string
+	editorVersions    []string
+	editorOutputFile  string
+)
+
+// editorCmd writes the files that make editors use the Kedge schema
+var editorCmd = &cobra.Command{
+	Use:   "editor",
+	Short: "Write the files that make editors use the Kedge schema.",
+	Long: `Write the files that make editors use the Kedge schema for Kedge files.
+
+The schema editors use is the one of whole Kedge files, which is written by
+--out-dir. Its location is the URL under output.idBase of the config,
+or its path in output.outDir, or the one given with --url.`,
+}
+
+// editorCatalogCmd writes SchemaStore catalog
+var editorCatalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Write SchemaStore catalog with the Kedge schema.",
+	Long: `Write catalog in the format of SchemaStore, with the entry of the Kedge
+schema, which can be added to SchemaStore or used as a catalog of its own.
+The schema and all of its versions need an http or https URL.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := editorCatalog(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+// editorSettingsCmd writes yaml.schemas settings
+var editorSettingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "Write yaml.schemas settings of yaml-language-server for the Kedge schema.",
+	Long: `Write 'yaml.schemas' settings of yaml-language-server, which is used e.g.
+by the YAML extension of VS Code, so that it uses the Kedge schema for Kedge
+files. Copy them into .vscode/settings.json of your project.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := editorSettings(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func editorCatalog(cmd *cobra.Command) error {
+	location, err := editorSchemaLocation(cmd)
+	if err != nil {
+		return err
+	}
+	entry := pkg.CatalogEntry{
+		Name:        editorName,
+		Description: editorDescription,
+		FileMatch:   editorFileMatch,
+		URL:         location,
+	}
+	for _, v := range editorVersions {
+		version, url, err := pkg.ParseSchemaVersion(v)
+		if err != nil {
+			return err
+		}
+		if entry.Versions == nil {
+			entry.Versions = make(map[string]string)
+		}
+		entry.Versions[version] = url
+	}
+	catalog, err := pkg.NewSchemaCatalog(entry)
+	if err != nil {
+		return err
+	}
+	return writeEditorFile(catalog)
+}
+
+func editorSettings(cmd *cobra.Command) error {
+	location, err := editorSchemaLocation(cmd)
+	if err != nil {
+		return err
+	}
+	return writeEditorFile(pkg.YAMLSchemasSettings(location, editorFileMatch))
+}
+
+// Returns the URL or path of the Kedge schema
+func editorSchemaLocation(cmd *cobra.Command) (string, error) {
+	if editorURL != "" {
+		return editorURL, nil
+	}
+	cfg, err := generationConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+	// the file name depends on the definitions that are written
+	api, err := pkg.GenerateOpenAPI(cfg)
+	if err != nil {
+		return "", err
+	}
+	return pkg.AppSchemaLocation(api.Definitions, cfg.Output)
+}
+
+func writeEditorFile(v interface{}) error {
+	var w io.Writer = os.Stdout
+	if editorOutputFile != "" {
+		f, err := os.Create(editorOutputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return pkg.WriteJSON(w, v)
+}
+
+func init() {
+	for _, c := range []*cobra.Command{editorCatalogCmd, editorSettingsCmd} {
+		addGenerationFlags(c)
+		c.Flags().StringVar(&outDir, "out-dir", "", "Directory the definitions were written to by 'schemagen --out-dir'")
+		c.Flags().StringVar(&idBase, "id-base", "", "URL the files in --out-dir are published under")
+		c.Flags().StringVar(&draft, "draft", "", "JSON Schema draft the files in --out-dir were written in")
+		c.Flags().StringVar(&editorURL, "url", "", "URL or path of the Kedge schema, instead of the one written by --out-dir")
+		c.Flags().StringSliceVar(&editorFileMatch, "file-match", pkg.DefaultFileMatch, "Globs of the files to use the schema for, can be given multiple times")
+		c.Flags().StringVar(&editorOutputFile, "output-file", "", "File to write to instead of standard output")
+		editorCmd.AddCommand(c)
+	}
+	editorCatalogCmd.Flags().StringVar(&editorName, "name", "Kedge", "Name of the schema in the catalog")
+	editorCatalogCmd.Flags().StringVar(&editorDescription, "description", "Kedge application definition", "Description of the schema in the catalog")
+	editorCatalogCmd.Flags().StringArrayVar(&editorVersions, "version", nil, "Version of the schema and its URL as <version>=<url>, can be given multiple times")
+	RootCmd.AddCommand(editorCmd)
+}
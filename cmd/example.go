@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exampleSchema     string
+	exampleFull       bool
+	exampleOutputFile string
+)
+
+// exampleCmd writes example Kedge files
+var exampleCmd = &cobra.Command{
+	Use:   "example root",
+	Short: "Generate an example Kedge file to start from.",
+	Long: `Generate an example Kedge file in YAML with the required fields set.
+
+The root is 'app' for a Kedge file, a controller name like 'job' for a Kedge
+file of that controller or a definition key. With --full, optional fields are
+listed too as commented out lines, and every field has its description above
+it:
+
+  schemagen example job --full --output-file job.yaml
+
+Values are taken from defaults and enums of the schema, or are placeholders
+that fit its limits. The example is validated against the schema before it
+is written.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("example needs exactly one argument, the root of the example")
+			os.Exit(-1)
+		}
+
+		if err := example(cmd, args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func example(cmd *cobra.Command, root string) error {
+	defs, err := loadDefinitions(cmd, exampleSchema)
+	if err != nil {
+		return err
+	}
+	out, err := pkg.GenerateExample(defs, root, exampleFull)
+	if err != nil {
+		return err
+	}
+	if exampleOutputFile == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return ioutil.WriteFile(exampleOutputFile, out, 0644)
+}
+
+func init() {
+	addGenerationFlags(exampleCmd)
+	exampleCmd.Flags().StringVar(&exampleSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	exampleCmd.Flags().BoolVar(&exampleFull, "full", false, "List optional fields as commented out lines, with descriptions of the fields")
+	exampleCmd.Flags().StringVar(&exampleOutputFile, "output-file", "", "File to write the example to instead of standard output")
+	RootCmd.AddCommand(exampleCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainSchema    string
+	explainRecursive bool
+)
+
+// explainCmd describes fields of Kedge files
+var explainCmd = &cobra.Command{
+	Use:   "explain path",
+	Short: "Describe a field of Kedge files and the fields under it.",
+	Long: `Describe a field of Kedge files the way 'kubectl explain' does.
+
+The path starts with 'app' for the root of a Kedge file, a controller name
+like 'job' or a definition key, followed by field names separated by dots,
+e.g.
+
+  schemagen explain app.containers.health.httpGet
+  schemagen explain app.services.portMappings --recursive
+
+References are followed and arrays are stepped into. Type, description,
+whether the field is required and the fields under it are printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("explain needs exactly one argument, the path of the field")
+			os.Exit(-1)
+		}
+
+		if err := explain(cmd, args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func explain(cmd *cobra.Command, path string) error {
+	defs, err := loadDefinitions(cmd, explainSchema)
+	if err != nil {
+		return err
+	}
+	e := pkg.NewExplainer(defs)
+	x, err := e.Explain(path)
+	if err != nil {
+		return err
+	}
+	return e.WriteText(os.Stdout, x, explainRecursive)
+}
+
+func init() {
+	addGenerationFlags(explainCmd)
+	explainCmd.Flags().StringVar(&explainSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	explainCmd.Flags().BoolVar(&explainRecursive, "recursive", false, "Print all the fields nested under the field as a tree")
+	RootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fetchUpdate      bool
+	fetchOffline     bool
+	k8sRelease       string
+	k8sBaseURL       string
+	openshiftBaseURL string
+	openshiftVersion string
+	kedgeBaseURL     string
+	kedgeVersion     string
+	fetchTimeout     time.Duration
+)
+
+// fetchCmd downloads upstream files into the local cache
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch upstream schemas and Kedge spec into local cache.",
+	Long: `Fetch Kubernetes OpenAPI schema, OpenShift schema and Kedge types.go into
+local cache and record their URL, version and SHA-256 checksum in the lock file.
+
+Files already recorded in the lock file are taken from the cache without
+using the network, and it is an error if their content does not match the
+recorded checksum. Use --update to fetch them again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defaults := pkg.DefaultUpstreams(k8sRelease)
+		upstreams := []pkg.Upstream{}
+		for _, u := range defaults {
+			switch u.Name {
+			case pkg.UpstreamKubernetes:
+				u.BaseURL = k8sBaseURL
+			case pkg.UpstreamOpenShift:
+				u.BaseURL, u.Version = openshiftBaseURL, openshiftVersion
+			case pkg.UpstreamKedge:
+				u.BaseURL, u.Version = kedgeBaseURL, kedgeVersion
+			}
+			upstreams = append(upstreams, u)
+		}
+
+		f := &pkg.Fetcher{
+			CacheDir: cacheDir,
+			LockFile: lockFile,
+			Update:   fetchUpdate,
+			Offline:  fetchOffline,
+			Client:   &http.Client{Timeout: fetchTimeout},
+		}
+		if _, err := f.Fetch(upstreams); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func init() {
+	defaults := map[string]pkg.Upstream{}
+	for _, u := range pkg.DefaultUpstreams("release-1.7") {
+		defaults[u.Name] = u
+	}
+
+	addLockFlags(fetchCmd)
+	fetchCmd.Flags().BoolVar(&fetchUpdate, "update", false, "Fetch all the files again and update the lock file")
+	fetchCmd.Flags().DurationVar(&fetchTimeout, "timeout", pkg.DefaultFetchTimeout, "How long downloading a single file can take")
+	fetchCmd.Flags().BoolVar(&fetchOffline, "offline", false, "Never use the network, fail if any file is not cached")
+	fetchCmd.Flags().StringVar(&k8sRelease, "k8s-release", defaults[pkg.UpstreamKubernetes].Version, "Kubernetes release to fetch the OpenAPI schema for")
+	fetchCmd.Flags().StringVar(&k8sBaseURL, "k8s-base-url", defaults[pkg.UpstreamKubernetes].BaseURL, "Base URL to fetch Kubernetes OpenAPI schema from")
+	fetchCmd.Flags().StringVar(&openshiftBaseURL, "openshift-base-url", defaults[pkg.UpstreamOpenShift].BaseURL, "Base URL to fetch OpenShift schema from")
+	fetchCmd.Flags().StringVar(&openshiftVersion, "openshift-version", defaults[pkg.UpstreamOpenShift].Version, "OpenShift version to fetch the schema for")
+	fetchCmd.Flags().StringVar(&kedgeBaseURL, "kedge-base-url", defaults[pkg.UpstreamKedge].BaseURL, "Base URL to fetch Kedge types.go from")
+	fetchCmd.Flags().StringVar(&kedgeVersion, "kedge-version", defaults[pkg.UpstreamKedge].Version, "Kedge version to fetch types.go for")
+	RootCmd.AddCommand(fetchCmd)
+}
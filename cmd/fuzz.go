@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fuzzSchema       string
+	fuzzSeed         int64
+	fuzzCount        int
+	fuzzFormat       string
+	fuzzMaxRecursion int
+	fuzzOptionalRate float64
+)
+
+// fuzzCmd writes random valid values of definitions
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz root",
+	Short: "Generate random Kedge files that are valid against the schema.",
+	Long: `Generate random values that are valid against the schema, for fuzzing and
+property based tests of tools that read Kedge files.
+
+The root is 'app' for a Kedge file, a controller name like 'job' for a Kedge
+file of that controller or a definition key, the same as for 'example':
+
+  schemagen fuzz app --count 100 --seed 42 > corpus.yaml
+
+Types, enums, patterns, required fields and limits of the schema are kept to,
+optional fields are set at random and definitions are nested in themselves at
+most --max-recursion times. Values are written as YAML documents, or one JSON
+value after the other. The same seed gives the same values, the seed used is
+printed to standard error when it is not given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Println("fuzz needs exactly one argument, the root of the values")
+			os.Exit(-1)
+		}
+
+		if err := fuzz(cmd, args[0]); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func fuzz(cmd *cobra.Command, root string) error {
+	if err := pkg.CheckFormat(fuzzFormat); err != nil {
+		return err
+	}
+	defs, err := loadDefinitions(cmd, fuzzSchema)
+	if err != nil {
+		return err
+	}
+
+	seed := fuzzSeed
+	if !cmd.Flags().Changed("seed") {
+		seed = time.Now().UnixNano()
+		fmt.Fprintf(os.Stderr, "seed: %d\n", seed)
+	}
+	f := pkg.NewFuzzer(defs, seed)
+	f.MaxRecursion = fuzzMaxRecursion
+	f.OptionalRate = fuzzOptionalRate
+
+	for i := 0; i < fuzzCount; i++ {
+		v, err := f.Instance(root)
+		if err != nil {
+			return err
+		}
+		if i > 0 && fuzzFormat == pkg.FormatYAML {
+			fmt.Println("---")
+		}
+		if err := pkg.Write(os.Stdout, v, fuzzFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	addGenerationFlags(fuzzCmd)
+	fuzzCmd.Flags().StringVar(&fuzzSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	fuzzCmd.Flags().Int64Var(&fuzzSeed, "seed", 0, "Seed of the random values, random when not given")
+	fuzzCmd.Flags().IntVar(&fuzzCount, "count", 1, "Number of values to generate")
+	fuzzCmd.Flags().StringVar(&fuzzFormat, "format", pkg.FormatYAML, "Output format, one of: json, json-compact, yaml")
+	fuzzCmd.Flags().IntVar(&fuzzMaxRecursion, "max-recursion", pkg.DefaultFuzzRecursion, "How many times a definition can be nested in itself")
+	fuzzCmd.Flags().Float64Var(&fuzzOptionalRate, "optional-rate", pkg.DefaultFuzzOptionalRate, "Probability of an optional field being set")
+	RootCmd.AddCommand(fuzzCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genGoSchema     string
+	genGoPackage    string
+	genGoOutputFile string
+)
+
+// genGoCmd writes Go types of definitions
+var genGoCmd = &cobra.Command{
+	Use:   "gen-go [definition key...]",
+	Short: "Generate Go structs for Kedge definitions.",
+	Long: `Generate Go structs with json tags and doc comments for the given definitions
+and the definitions they refer to, for tools that read Kedge files.
+
+The definitions default to all the Kedge definitions. Structs follow the
+conventions of Kedge spec, so generating the schema from the written code
+gives the same definitions:
+
+  schemagen gen-go io.kedge.JobSpecMod --package kedge --output-file types.go
+
+The schema is generated from the given Kedge spec and upstream schema files,
+unless an already generated schema is given using --schema.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := genGo(cmd, args); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func genGo(cmd *cobra.Command, keys []string) error {
+	defs, err := loadDefinitions(cmd, genGoSchema)
+	if err != nil {
+		return err
+	}
+	src, err := pkg.GenerateGo(defs, keys, genGoPackage)
+	if err != nil {
+		return err
+	}
+	if genGoOutputFile == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(genGoOutputFile, src, 0644)
+}
+
+func init() {
+	addGenerationFlags(genGoCmd)
+	genGoCmd.Flags().StringVar(&genGoSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	genGoCmd.Flags().StringVar(&genGoPackage, "package", "kedge", "Name of the package of the generated code")
+	genGoCmd.Flags().StringVar(&genGoOutputFile, "output-file", "", "File to write the generated code to instead of standard output")
+	RootCmd.AddCommand(genGoCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genTSSchema     string
+	genTSOutputFile string
+)
+
+// genTSCmd writes TypeScript types of definitions
+var genTSCmd = &cobra.Command{
+	Use:   "gen-ts [definition key...]",
+	Short: "Generate TypeScript definitions for Kedge definitions.",
+	Long: `Generate TypeScript declarations, a '.d.ts' file, for the given definitions
+and the upstream definitions they refer to, for web tools that build Kedge
+files.
+
+The definitions default to all the Kedge definitions. Objects become
+interfaces whose fields are optional unless they are required, enums become
+unions of their values and descriptions become JSDoc comments:
+
+  schemagen gen-ts --output-file kedge.d.ts
+
+The schema is generated from the given Kedge spec and upstream schema files,
+unless an already generated schema is given using --schema.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := genTS(cmd, args); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func genTS(cmd *cobra.Command, keys []string) error {
+	defs, err := loadDefinitions(cmd, genTSSchema)
+	if err != nil {
+		return err
+	}
+	src, err := pkg.GenerateTypeScript(defs, keys)
+	if err != nil {
+		return err
+	}
+	if genTSOutputFile == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(genTSOutputFile, src, 0644)
+}
+
+func init() {
+	addGenerationFlags(genTSCmd)
+	genTSCmd.Flags().StringVar(&genTSSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	genTSCmd.Flags().StringVar(&genTSOutputFile, "output-file", "", "File to write the generated definitions to instead of standard output")
+	RootCmd.AddCommand(genTSCmd)
+}
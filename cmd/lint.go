@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintEnable    []string
+	lintDisable   []string
+	lintFormat    string
+	lintListRules bool
+)
+
+// lintCmd checks Kedge spec against the conventions of conversion.md
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check Kedge spec source code for the conventions the generator relies on.",
+	Long: `Check Kedge spec source code for the conventions described in conversion.md.
+
+The 'kedgeSpec:', 'k8s:', 'ref:' and '+optional' comments are what the
+generator works from, mistakes in them silently produce wrong definitions.
+Every rule can be enabled or disabled, use --list-rules to see them. Exits
+with 1 if any error is found, warnings don't fail.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if lintListRules {
+			for _, r := range pkg.LintRules {
+				fmt.Printf("%-20s %-8s %s\n", r.Name, r.Severity, r.Description)
+			}
+			return
+		}
+
+		diags, err := lint(cmd)
+		if err != nil {
+			// problems in the sources are reported like any other finding
+			d, ok := pkg.ErrorDiagnostic(err)
+			if !ok || lintFormat != "sarif" {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+			diags = []pkg.Diagnostic{d}
+		}
+
+		switch lintFormat {
+		case "text":
+			err = pkg.WriteDiagnosticsText(os.Stdout, diags)
+		case pkg.FormatJSON, pkg.FormatJSONCompact, pkg.FormatYAML:
+			if diags == nil {
+				diags = []pkg.Diagnostic{}
+			}
+			err = pkg.Write(os.Stdout, diags, lintFormat)
+		case "sarif":
+			err = pkg.WriteSARIF(os.Stdout, diags)
+		default:
+			err = fmt.Errorf("unknown output format %q", lintFormat)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+
+		if pkg.HasErrors(diags) {
+			os.Exit(1)
+		}
+	},
+}
+
+func lint(cmd *cobra.Command) ([]pkg.Diagnostic, error) {
+	rules, err := pkg.SelectLintRules(lintEnable, lintDisable)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(cmd)
+	if err == nil {
+		err = cfg.ValidateInputs()
+	}
+	if err != nil {
+		return nil, err
+	}
+	g, err := pkg.NewGenerator(cfg.GeneratorOptions())
+	if err != nil {
+		return nil, err
+	}
+	return pkg.Lint(g, rules)
+}
+
+func init() {
+	addGenerationFlags(lintCmd)
+	lintCmd.Flags().StringSliceVar(&lintEnable, "enable", nil, "Only run these rules, can be given multiple times")
+	lintCmd.Flags().StringSliceVar(&lintDisable, "disable", nil, "Do not run these rules, can be given multiple times")
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "Output format, one of: text, json, json-compact, yaml, sarif")
+	lintCmd.Flags().BoolVar(&lintListRules, "list-rules", false, "List all the rules and exit")
+	RootCmd.AddCommand(lintCmd)
+}
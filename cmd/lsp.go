@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lspSchema string
+	lspRoot   string
+	lspStrict bool
+)
+
+// lspCmd runs the language server for Kedge files
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for Kedge files over stdio.",
+	Long: `Run a Language Server Protocol server for Kedge files over stdin and stdout.
+
+The schema is generated once when the server starts, from the given Kedge
+spec and upstream schema files unless an already generated schema is given
+using --schema. Editors get completion of field names and enum values,
+descriptions of fields on hover and validation errors as they type.
+
+Stdout is used for the protocol, so errors and logs are written to stderr.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := serveLSP(cmd); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+func serveLSP(cmd *cobra.Command) error {
+	defs, err := loadDefinitions(cmd, lspSchema)
+	if err != nil {
+		return err
+	}
+	s, err := pkg.NewLanguageServer(defs, lspRoot, lspStrict)
+	if err != nil {
+		return err
+	}
+	return s.Serve(os.Stdin, os.Stdout)
+}
+
+func init() {
+	addGenerationFlags(lspCmd)
+	lspCmd.Flags().StringVar(&lspSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	lspCmd.Flags().StringVar(&lspRoot, "root", pkg.AppKey, "Key of the definition to validate files against")
+	lspCmd.Flags().BoolVar(&lspStrict, "strict", false, "Do not allow fields that are not defined in the schema")
+	RootCmd.AddCommand(lspCmd)
+}
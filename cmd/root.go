@@ -14,6 +14,24 @@ var (
 	kedgeSpecLocation string
 	kubernetesSchema  string
 	openshiftSchema   string
+	prune             bool
+	roots             []string
+	k8sVersions       []string
+	releasesDir       string
+	outputFile        string
+	locked            bool
+	lockFile          string
+	cacheDir          string
+	schemaCacheDir    string
+	noCache           bool
+	clearCache        bool
+	check             bool
+	sarifFile         string
+	outputFormat      string
+	outDir            string
+	bundle            bool
+	draft             string
+	idBase            string
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -25,15 +43,128 @@ var RootCmd = &cobra.Command{
 		if verbose {
 			log.SetLevel(log.DebugLevel)
 		}
+		if clearCache {
+			cache, err := schemaCache()
+			if err == nil {
+				err = cache.Clear()
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := pkg.Conversion(kedgeSpecLocation, kubernetesSchema, openshiftSchema); err != nil {
+		if watch {
+			if err := runWatch(cmd); err != nil {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+			return
+		}
+		if err := generate(cmd); err != nil {
 			fmt.Println(err)
+			if _, ok := err.(*pkg.DriftError); ok {
+				os.Exit(1)
+			}
 			os.Exit(-1)
 		}
 	},
 }
 
+func generate(cmd *cobra.Command) (err error) {
+	var diags []pkg.Diagnostic
+	if sarifFile != "" {
+		// the error that stopped generation is a diagnostic too
+		defer func() {
+			if d, ok := pkg.ErrorDiagnostic(err); ok {
+				diags = append(diags, d)
+			}
+			if werr := writeSARIFFile(sarifFile, diags); werr != nil && err == nil {
+				err = werr
+			}
+		}()
+	}
+
+	cfg, err := generationConfig(cmd)
+	if err != nil {
+		return err
+	}
+	cfg.Diagnostics = func(d pkg.Diagnostic) {
+		log.Warnln(d)
+		diags = append(diags, d)
+	}
+
+	if len(cfg.Upstream.Releases) > 0 {
+		// generate for matrix of Kubernetes releases
+		return pkg.GenerateMatrix(cfg)
+	}
+	return pkg.Conversion(cfg)
+}
+
+// Returns the config with the flags deciding the output applied on top of it
+func generationConfig(cmd *cobra.Command) (*pkg.Config, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// flags deciding the output override the config
+	flags := cmd.Flags()
+	if flags.Changed("prune") {
+		cfg.Output.Prune = prune
+	}
+	if flags.Changed("root") {
+		cfg.Output.Roots = roots
+	}
+	if flags.Changed("output-file") {
+		cfg.Output.File = outputFile
+	}
+	if flags.Changed("releases-dir") {
+		cfg.Output.ReleasesDir = releasesDir
+	}
+	if flags.Changed("out-dir") {
+		cfg.Output.OutDir = outDir
+	}
+	if flags.Changed("bundle") {
+		cfg.Output.Bundle = bundle
+	}
+	if flags.Changed("draft") {
+		cfg.Output.Draft = draft
+	}
+	if flags.Changed("id-base") {
+		cfg.Output.IDBase = idBase
+	}
+	if flags.Changed("format") {
+		cfg.Output.Format = outputFormat
+	}
+	cfg.Output.Check = check
+	if err := applyReleaseFlags(cmd, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Replaces the Kubernetes releases in config with the ones given by
+// --k8s-version
+func applyReleaseFlags(cmd *cobra.Command, cfg *pkg.Config) error {
+	if !cmd.Flags().Changed("k8s-version") {
+		return nil
+	}
+	cfg.Upstream.Releases = nil
+	for _, v := range k8sVersions {
+		r, err := pkg.ParseRelease(v)
+		if err != nil {
+			return err
+		}
+		cfg.Upstream.Releases = append(cfg.Upstream.Releases, r)
+	}
+	return nil
+}
+
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -41,9 +172,41 @@ func Execute() {
 	}
 }
 
+// Adds the flags that specify input files needed to generate the OpenAPI
+// schema, to the commands that generate it
+func addGenerationFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&kedgeSpecLocation, "kedgespec", "k", "types.go", "Specify the location of Kedge spec file")
+	cmd.Flags().StringVarP(&kubernetesSchema, "k8sSchema", "s", "swagger.json", "Specify the location of Kuberenetes Schema file")
+	cmd.Flags().StringVarP(&openshiftSchema, "osSchema", "o", "osv2.json", "Specify the location of OpenShift schema file")
+	cmd.Flags().BoolVar(&locked, "locked", false, "Use Kedge spec, Kubernetes and OpenShift schemas fetched by 'schemagen fetch' instead of --kedgespec, --k8sSchema and --osSchema")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "Parse upstream schemas without using or updating the cache of parsed schemas")
+	cmd.Flags().BoolVar(&clearCache, "clear-cache", false, "Remove all parsed schemas from cache before running")
+	cmd.Flags().StringVar(&schemaCacheDir, "schema-cache-dir", "", "Directory where parsed schemas are cached, defaults to schemagen/parsed in the cache directory of the user")
+	addLockFlags(cmd)
+}
+
+// Adds the flags that specify where fetched files are cached and recorded
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&lockFile, "lock-file", "schemagen.lock", "Lock file recording the fetched files")
+	cmd.Flags().StringVar(&cacheDir, "cache-dir", ".schemagen/cache", "Directory where fetched files are cached")
+}
+
 func init() {
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
-	RootCmd.Flags().StringVarP(&kedgeSpecLocation, "kedgespec", "k", "types.go", "Specify the location of Kedge spec file")
-	RootCmd.Flags().StringVarP(&kubernetesSchema, "k8sSchema", "s", "swagger.json", "Specify the location of Kuberenetes Schema file")
-	RootCmd.Flags().StringVarP(&openshiftSchema, "osSchema", "o", "osv2.json", "Specify the location of OpenShift schema file")
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file, defaults to "+pkg.ConfigFileName+" in the working directory if it exists")
+	addGenerationFlags(RootCmd)
+	RootCmd.Flags().BoolVarP(&prune, "prune", "p", false, "Only output definitions reachable from Kedge definitions")
+	RootCmd.Flags().StringSliceVarP(&roots, "root", "r", nil, "Definition key to start pruning from, can be given multiple times, implies --prune")
+	RootCmd.Flags().StringVar(&sarifFile, "sarif-file", "", "Also write the problems found while generating to this file as SARIF")
+	RootCmd.Flags().BoolVar(&check, "check", false, "Do not write output, exit with 1 if the output files are not up to date")
+	RootCmd.Flags().StringArrayVar(&k8sVersions, "k8s-version", nil, "Kubernetes release and its schema file as <version>=<file>, can be given multiple times, overrides --k8sSchema")
+	RootCmd.Flags().StringVar(&releasesDir, "releases-dir", "", "Directory to write schema of every Kubernetes release to, needed by --k8s-version")
+	RootCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Keep running and generate again whenever the inputs change, needs an output file or directory")
+	RootCmd.Flags().DurationVar(&watchInterval, "watch-interval", pkg.DefaultWatchInterval, "How often inputs are checked for changes with --watch")
+	RootCmd.Flags().StringVar(&outputFormat, "format", pkg.FormatJSON, "Format of the generated schema, one of: json, json-compact, yaml")
+	RootCmd.Flags().StringVar(&outDir, "out-dir", "", "Directory to write every definition to in its own file, with references between the files and an index of them")
+	RootCmd.Flags().BoolVar(&bundle, "bundle", false, "Also write all the definitions under '$defs' to a single file in --out-dir")
+	RootCmd.Flags().StringVar(&draft, "draft", "", "JSON Schema draft of the files in --out-dir, one of: 4, 6, 7, 2019-09, 2020-12")
+	RootCmd.Flags().StringVar(&idBase, "id-base", "", "URL the files in --out-dir are published under, used for their '$id', needs --draft")
+	RootCmd.Flags().StringVar(&outputFile, "output-file", "", "File to write the generated schema to instead of standard output")
 }
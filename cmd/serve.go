@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	serveVersion string
+	serveDraft   string
+	serveIDBase  string
+	serveStrict  bool
+)
+
+// serveCmd serves the generated schemas over HTTP
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the generated schemas over HTTP and validate Kedge files posted to it.",
+	Long: `Serve the generated schemas over HTTP, generating them again whenever the
+inputs change.
+
+Every Kubernetes release given by --k8s-version or in the config is served
+under its own path, or the schema of --k8sSchema under --version:
+
+  /index.json              versions that are served
+  /v1.7/openapi.json       OpenAPI schema
+  /v1.7/app.json           JSON Schema of Kedge files, and the other
+                           definitions in their own files
+  /v1.7/index.json         files of the definitions
+  /v1.7/bundle.json        all the definitions in a single file
+  /v1.7/validate           POST a Kedge file to validate it
+  /validate                same, against the last version
+
+Validation responds with JSON like '{"valid": false, "errors": [...]}', the
+definition to validate against can be given with the 'root' parameter.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runServe(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+	},
+}
+
+func runServe(cmd *cobra.Command) error {
+	load := func() (*pkg.Config, error) {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyReleaseFlags(cmd, cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	// wrong flags are found before starting to serve
+	if _, err := load(); err != nil {
+		return err
+	}
+	if err := pkg.CheckDraft(serveDraft); err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+		l.Close()
+	}()
+
+	s := &pkg.SchemaServer{
+		Load:       load,
+		ConfigFile: configPath(),
+		Interval:   watchInterval,
+		Version:    serveVersion,
+		Draft:      serveDraft,
+		IDBase:     serveIDBase,
+		Strict:     serveStrict,
+		OnResult: func(r pkg.ServeResult) {
+			pkg.WriteServeResultText(os.Stdout, r)
+		},
+	}
+	go s.Run(stop)
+
+	fmt.Printf("serving schemas at http://%s/\n", l.Addr())
+	err = http.Serve(l, s)
+	select {
+	case <-stop:
+		return nil
+	default:
+		return err
+	}
+}
+
+func init() {
+	addGenerationFlags(serveCmd)
+	serveCmd.Flags().StringArrayVar(&k8sVersions, "k8s-version", nil, "Kubernetes release and its schema file as <version>=<file>, can be given multiple times, overrides --k8sSchema")
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveVersion, "version", pkg.DefaultServeVersion, "Version to serve the schema under when no Kubernetes releases are given")
+	serveCmd.Flags().StringVar(&serveDraft, "draft", pkg.Draft7, "JSON Schema draft of the definitions, one of: 4, 6, 7, 2019-09, 2020-12")
+	serveCmd.Flags().StringVar(&serveIDBase, "id-base", "", "URL the server is reached at, used for the '$id' of the definitions")
+	serveCmd.Flags().BoolVar(&serveStrict, "strict", false, "Do not allow fields that are not defined in the schema when validating")
+	serveCmd.Flags().DurationVar(&watchInterval, "watch-interval", pkg.DefaultWatchInterval, "How often inputs are checked for changes")
+	RootCmd.AddCommand(serveCmd)
+}
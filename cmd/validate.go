@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateSchema string
+	validateRoot   string
+	validateStrict bool
+	validateFormat string
+)
+
+// validateCmd validates Kedge files against the generated schema
+var validateCmd = &cobra.Command{
+	Use:   "validate file...",
+	Short: "Validate Kedge YAML or JSON files against the generated schema.",
+	Long: `Validate Kedge YAML or JSON files against the generated schema.
+
+The schema is generated from the given Kedge spec and upstream schema files,
+unless an already generated schema is given using --schema. Every document
+of a multi-document YAML file is validated. Exits with 1 if any file is
+invalid.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			fmt.Println("validate needs at least one file to validate")
+			os.Exit(-1)
+		}
+
+		errs, err := validate(cmd, args)
+		if err != nil {
+			// problems in the inputs are reported like validation errors
+			d, ok := pkg.ErrorDiagnostic(err)
+			if !ok || validateFormat != "sarif" {
+				fmt.Println(err)
+				os.Exit(-1)
+			}
+			if err := pkg.WriteSARIF(os.Stdout, []pkg.Diagnostic{d}); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+
+		switch validateFormat {
+		case "text":
+			err = pkg.WriteValidationErrorsText(os.Stdout, errs)
+		case pkg.FormatJSON, pkg.FormatJSONCompact, pkg.FormatYAML:
+			if errs == nil {
+				errs = []pkg.ValidationError{}
+			}
+			err = pkg.Write(os.Stdout, errs, validateFormat)
+		case "sarif":
+			diags := []pkg.Diagnostic{}
+			for _, e := range errs {
+				diags = append(diags, e.Diagnostic())
+			}
+			err = pkg.WriteSARIF(os.Stdout, diags)
+		default:
+			err = fmt.Errorf("unknown output format %q", validateFormat)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
+
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func validate(cmd *cobra.Command, files []string) ([]pkg.ValidationError, error) {
+	defs, err := loadDefinitions(cmd, validateSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	v := pkg.NewValidator(defs, validateStrict)
+	var errs []pkg.ValidationError
+	for _, f := range files {
+		e, err := v.ValidateFile(validateRoot, f)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, e...)
+	}
+	return errs, nil
+}
+
+func init() {
+	addGenerationFlags(validateCmd)
+	validateCmd.Flags().StringVar(&validateSchema, "schema", "", "Use already generated OpenAPI schema file instead of generating it")
+	validateCmd.Flags().StringVar(&validateRoot, "root", pkg.AppKey, "Key of the definition to validate files against")
+	validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "Do not allow fields that are not defined in the schema")
+	validateCmd.Flags().StringVar(&validateFormat, "format", "text", "Output format, one of: text, json, json-compact, yaml, sarif")
+	RootCmd.AddCommand(validateCmd)
+}
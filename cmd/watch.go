@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kedgeproject/json-schema-generator/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watch         bool
+	watchInterval time.Duration
+)
+
+// Generates the schema and again whenever the inputs change, until
+// interrupted
+func runWatch(cmd *cobra.Command) error {
+	load := func() (*pkg.Config, error) {
+		cfg, err := generationConfig(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Output.Check {
+			return nil, errors.New("--watch can't be used with --check")
+		}
+		if len(cfg.Upstream.Releases) > 0 {
+			return nil, errors.New("--watch can't be used with Kubernetes releases")
+		}
+		if cfg.Output.File == "" && cfg.Output.OutDir == "" {
+			return nil, errors.New("--watch needs an output file or directory, use --output-file or --out-dir")
+		}
+		return cfg, nil
+	}
+	// wrong flags are found before starting to watch
+	if _, err := load(); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	w := &pkg.Watcher{
+		Load:       load,
+		ConfigFile: configPath(),
+		Interval:   watchInterval,
+		OnResult: func(r pkg.WatchResult) {
+			pkg.WriteWatchResultText(os.Stdout, r)
+			if sarifFile == "" {
+				return
+			}
+			diags := r.Diagnostics
+			if d, ok := pkg.ErrorDiagnostic(r.Err); ok {
+				diags = append(diags, d)
+			}
+			if err := writeSARIFFile(sarifFile, diags); err != nil {
+				fmt.Println(err)
+			}
+		},
+	}
+	return w.Run(stop)
+}
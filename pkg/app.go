@@ -0,0 +1,121 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/go-openapi/spec"
+)
+
+// Key of the root definition which can validate any Kedge file
+const AppKey = "io.kedge.App"
+
+// Name of the field in Kedge file that decides the controller
+const ControllerField = "controller"
+
+// Controller is a Kubernetes or OpenShift controller supported by Kedge
+type Controller struct {
+	// Value of the 'controller' field in Kedge file
+	Name string
+	// Key of the definition that validates files for this controller
+	Key string
+}
+
+// All the controllers supported by Kedge, the first one is the default
+// which is used when 'controller' field is not given
+var Controllers = []Controller{
+	{Name: "deployment", Key: "io.kedge.DeploymentSpecMod"},
+	{Name: "job", Key: "io.kedge.JobSpecMod"},
+	{Name: "deploymentconfig", Key: "io.kedge.DeploymentConfigSpecMod"},
+}
+
+// Adds the root 'app' definition to defs, which dispatches to the definition
+// of the controller mentioned in the 'controller' field. Every controller is
+// one branch of 'oneOf', the branches are mutually exclusive since each of
+// them allows only its own controller name and all but the default one
+// require the field, so errors from only the matching branch are relevant.
+// Controllers whose definitions are not found in defs are left out. A
+// definition with the same key that is already in defs is replaced and
+// reported.
+func CreateAppDefinition(defs spec.Definitions, report func(Diagnostic)) {
+	var names []interface{}
+	var branches []spec.Schema
+	for i, c := range Controllers {
+		if _, ok := defs[c.Key]; !ok {
+			continue
+		}
+		names = append(names, c.Name)
+
+		branch := spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Title: c.Name,
+				Properties: map[string]spec.Schema{
+					ControllerField: {
+						SchemaProps: spec.SchemaProps{
+							Enum: []interface{}{c.Name},
+						},
+					},
+				},
+				AllOf:                []spec.Schema{refSchema(c.Key)},
+				AdditionalProperties: allowAdditional(),
+			},
+		}
+		// the default controller is used even if the field is not given
+		if i != 0 {
+			branch.Required = []string{ControllerField}
+		}
+		branches = append(branches, branch)
+	}
+	if len(branches) == 0 {
+		return
+	}
+	if _, ok := defs[AppKey]; ok {
+		report(Diagnostic{
+			Rule:     RuleAppReplaced,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("definition %q is replaced by the generated root definition", AppKey),
+		})
+	}
+
+	defs[AppKey] = spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Description: "App is the root of a Kedge file, the 'controller' field decides " +
+				"which controller's definition is used to validate the rest of the file, " +
+				"defaults to '" + Controllers[0].Name + "'",
+			Type: spec.StringOrArray([]string{"object"}),
+			Properties: map[string]spec.Schema{
+				ControllerField: {
+					SchemaProps: spec.SchemaProps{
+						Description: "The controller to use for this application",
+						Type:        spec.StringOrArray([]string{"string"}),
+						Enum:        names,
+					},
+				},
+			},
+			OneOf:                branches,
+			AdditionalProperties: allowAdditional(),
+		},
+	}
+}
+
+// Fields of the Kedge file are validated by the controller's definition, so
+// the schemas that only dispatch to it should not reject any field even if
+// strict validation is used
+func allowAdditional() *spec.SchemaOrBool {
+	return &spec.SchemaOrBool{Allows: true}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateAppDefinitionBranches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	api, err := GenerateOpenAPI(testConfig(t, dir))
+	if err != nil {
+		t.Fatalf("could not generate: %v", err)
+	}
+	defs := api.Definitions
+
+	// every branch on its own, to find out which of them a file matches
+	var branches []string
+	for i, b := range defs[AppKey].OneOf {
+		key := fmt.Sprintf("test.Branch%d", i)
+		defs[key] = b
+		branches = append(branches, key)
+	}
+	v := NewValidator(defs, false)
+
+	tests := []struct {
+		name       string
+		controller string
+		// titles of the branches the file matches
+		matches []string
+	}{
+		{"no controller", "", []string{"deployment"}},
+		{"default controller", "controller: deployment\n", []string{"deployment"}},
+		{"other controller", "controller: job\n", []string{"job"}},
+		{"controller not defined", "controller: deploymentconfig\n", nil},
+		{"unknown controller", "controller: cronjob\n", nil},
+	}
+	for _, test := range tests {
+		file := test.controller + "name: web\ncontainers:\n- name: nginx\n"
+		var matches []string
+		for i, key := range branches {
+			errs, err := v.ValidateReader(key, "app.yaml", strings.NewReader(file))
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if len(errs) == 0 {
+				matches = append(matches, defs[AppKey].OneOf[i].Title)
+			}
+		}
+		if !reflect.DeepEqual(matches, test.matches) {
+			t.Errorf("%s: expected to match branches %v, got %v", test.name, test.matches, matches)
+		}
+
+		errs, err := v.ValidateReader(AppKey, "app.yaml", strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if valid := len(errs) == 0; valid != (len(test.matches) == 1) {
+			t.Errorf("%s: expected valid to be %v, got %v", test.name, len(test.matches) == 1, errs)
+		}
+	}
+}
+
+func TestCreateAppDefinitionReplaced(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {"type": "object"},
+		"io.kedge.JobSpecMod": {"type": "object"}
+	}`)
+	var diags []Diagnostic
+	CreateAppDefinition(defs, func(d Diagnostic) { diags = append(diags, d) })
+	if len(diags) != 1 || diags[0].Rule != RuleAppReplaced {
+		t.Errorf("expected the replaced definition to be reported, got %v", diags)
+	}
+	if len(defs[AppKey].OneOf) != 1 || defs[AppKey].OneOf[0].Title != "job" {
+		t.Errorf("expected the root definition to have a branch for job only, got %+v", defs[AppKey].OneOf)
+	}
+
+	diags = nil
+	CreateAppDefinition(testDefinitions(t, `{"io.kedge.JobSpecMod": {"type": "object"}}`), func(d Diagnostic) { diags = append(diags, d) })
+	if len(diags) != 0 {
+		t.Errorf("expected nothing to be reported, got %v", diags)
+	}
+}
@@ -0,0 +1,640 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// Changes whenever cached entries are written differently, so that entries
+// written before are not read
+const cacheFormat = "1"
+
+// SchemaCache keeps parsed upstream schemas on disk so that the big
+// Kubernetes schema is not unmarshalled from JSON on every run. Entries are
+// named after the hash of the schema content and of the schemagen binary,
+// so they never need to be invalidated, a changed file or a rebuilt binary
+// that may parse differently simply uses new entries. The cache is only ever
+// an optimisation, entries that can't be read or written are parsed again.
+type SchemaCache struct {
+	Dir string
+}
+
+// Returns the cache keeping its entries in dir
+func NewSchemaCache(dir string) *SchemaCache {
+	return &SchemaCache{Dir: dir}
+}
+
+// Returns the directory parsed schemas are cached in by default, which is
+// under the cache directory of the user so that runs in any directory share
+// it and nothing is left in the working directory
+func DefaultSchemaCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not find cache directory")
+	}
+	return filepath.Join(dir, "schemagen", "parsed"), nil
+}
+
+// Parses the OpenAPI schema just like ParseSwagger, but reads it from the
+// cache if it was parsed before
+func (c *SchemaCache) Parse(src Source) (*spec.Swagger, error) {
+	content, err := src.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	filename := c.entry(content)
+	if api, err := readCacheEntry(filename); err == nil {
+		log.Debugf("using cached schema %q for %q", filename, src.Name)
+		return api, nil
+	} else if !os.IsNotExist(err) {
+		log.Debugf("could not read cached schema %q: %v", filename, err)
+	}
+
+	api, err := unmarshalSwagger(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCacheEntry(filename, api); err != nil {
+		log.Debugf("could not cache schema %q: %v", src.Name, err)
+	}
+	return api, nil
+}
+
+// Removes all the entries of the cache
+func (c *SchemaCache) Clear() error {
+	return errors.Wrap(os.RemoveAll(c.Dir), "could not clear cache")
+}
+
+// Returns the file the entry for the content is kept in
+func (c *SchemaCache) entry(content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(cacheBuild() + "\x00" + cacheFormat + "\x00"))
+	h.Write(content)
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+".gob")
+}
+
+var (
+	buildOnce sync.Once
+	buildHash string
+)
+
+// Returns the hash of the running binary, entries are not shared between
+// builds since the version is not changed for every change of the parser.
+// The version is used if the binary can't be read.
+func cacheBuild() string {
+	buildOnce.Do(func() {
+		buildHash = Version
+		filename, err := os.Executable()
+		if err != nil {
+			log.Debugf("could not find executable, cache entries are keyed on version: %v", err)
+			return
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			log.Debugf("could not read executable, cache entries are keyed on version: %v", err)
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			log.Debugf("could not read executable, cache entries are keyed on version: %v", err)
+			return
+		}
+		buildHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return buildHash
+}
+
+// cacheEntry is how parsed schema is kept on disk. Definitions are the bulk
+// of upstream schemas and slow to unmarshal from JSON, so they are kept in
+// gob, the rest of the schema is kept as JSON. Reading them is several times
+// faster than unmarshalling the JSON, see BenchmarkSchemaCache.
+type cacheEntry struct {
+	Swagger     []byte
+	Definitions map[string]cachedSchema
+}
+
+func readCacheEntry(filename string) (*spec.Swagger, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		return nil, err
+	}
+	api, err := unmarshalSwagger(entry.Swagger)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range entry.Definitions {
+		if api.Definitions[k], err = v.schema(); err != nil {
+			return nil, err
+		}
+	}
+	return api, nil
+}
+
+func writeCacheEntry(filename string, api *spec.Swagger) error {
+	rest := *api
+	rest.Definitions = nil
+	b, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	entry := cacheEntry{Swagger: b, Definitions: make(map[string]cachedSchema)}
+	for k, v := range api.Definitions {
+		if entry.Definitions[k], err = newCachedSchema(v); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	// written to other file first, so that runs at the same time never
+	// read an entry that is written only partly
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".entry")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// cachedSchema mirrors spec.Schema in a form gob can encode. References
+// keep their state in unexported fields, so they are kept as strings.
+// Values of any type are kept as JSON, and the optional numbers are kept
+// with a flag as gob doesn't send zero values, it would lose e.g. a minimum
+// of 0. TestCachedSchemaFields fails when spec.Schema has a field that is
+// not kept here.
+type cachedSchema struct {
+	ID                   string
+	Ref                  string
+	Schema               string
+	Description          string
+	Type                 []string
+	Format               string
+	Title                string
+	Default              []byte
+	Maximum              cachedFloat
+	ExclusiveMaximum     bool
+	Minimum              cachedFloat
+	ExclusiveMinimum     bool
+	MaxLength            cachedInt
+	MinLength            cachedInt
+	Pattern              string
+	MaxItems             cachedInt
+	MinItems             cachedInt
+	UniqueItems          bool
+	MultipleOf           cachedFloat
+	Enum                 []byte
+	MaxProperties        cachedInt
+	MinProperties        cachedInt
+	Required             []string
+	Items                *cachedSchema
+	ItemsList            []cachedSchema
+	AllOf                []cachedSchema
+	OneOf                []cachedSchema
+	AnyOf                []cachedSchema
+	Not                  *cachedSchema
+	Properties           map[string]cachedSchema
+	AdditionalProperties *cachedSchemaOrBool
+	PatternProperties    map[string]cachedSchema
+	Dependencies         map[string]cachedDependency
+	AdditionalItems      *cachedSchemaOrBool
+	Definitions          map[string]cachedSchema
+	Discriminator        string
+	ReadOnly             bool
+	XML                  []byte
+	ExternalDocs         []byte
+	Example              []byte
+	Extensions           []byte
+	ExtraProps           []byte
+}
+
+type cachedFloat struct {
+	Set   bool
+	Value float64
+}
+
+type cachedInt struct {
+	Set   bool
+	Value int64
+}
+
+type cachedSchemaOrBool struct {
+	Allows bool
+	Schema *cachedSchema
+}
+
+type cachedDependency struct {
+	Schema   *cachedSchema
+	Property []string
+}
+
+func newCachedSchema(s spec.Schema) (cachedSchema, error) {
+	c := cachedSchema{
+		ID:               s.ID,
+		Ref:              s.Ref.String(),
+		Schema:           string(s.Schema),
+		Description:      s.Description,
+		Type:             s.Type,
+		Format:           s.Format,
+		Title:            s.Title,
+		Maximum:          newCachedFloat(s.Maximum),
+		ExclusiveMaximum: s.ExclusiveMaximum,
+		Minimum:          newCachedFloat(s.Minimum),
+		ExclusiveMinimum: s.ExclusiveMinimum,
+		MaxLength:        newCachedInt(s.MaxLength),
+		MinLength:        newCachedInt(s.MinLength),
+		Pattern:          s.Pattern,
+		MaxItems:         newCachedInt(s.MaxItems),
+		MinItems:         newCachedInt(s.MinItems),
+		UniqueItems:      s.UniqueItems,
+		MultipleOf:       newCachedFloat(s.MultipleOf),
+		MaxProperties:    newCachedInt(s.MaxProperties),
+		MinProperties:    newCachedInt(s.MinProperties),
+		Required:         s.Required,
+		Discriminator:    s.Discriminator,
+		ReadOnly:         s.ReadOnly,
+	}
+
+	var err error
+	for _, v := range []struct {
+		value interface{}
+		dst   *[]byte
+	}{
+		{s.Default, &c.Default},
+		{s.Enum, &c.Enum},
+		{s.XML, &c.XML},
+		{s.ExternalDocs, &c.ExternalDocs},
+		{s.Example, &c.Example},
+		{s.Extensions, &c.Extensions},
+		{s.ExtraProps, &c.ExtraProps},
+	} {
+		if *v.dst, err = marshalCached(v.value); err != nil {
+			return c, err
+		}
+	}
+
+	if s.Items != nil {
+		if s.Items.Schema != nil {
+			item, err := newCachedSchema(*s.Items.Schema)
+			if err != nil {
+				return c, err
+			}
+			c.Items = &item
+		}
+		if c.ItemsList, err = newCachedSchemas(s.Items.Schemas); err != nil {
+			return c, err
+		}
+	}
+	if c.AllOf, err = newCachedSchemas(s.AllOf); err != nil {
+		return c, err
+	}
+	if c.OneOf, err = newCachedSchemas(s.OneOf); err != nil {
+		return c, err
+	}
+	if c.AnyOf, err = newCachedSchemas(s.AnyOf); err != nil {
+		return c, err
+	}
+	if s.Not != nil {
+		not, err := newCachedSchema(*s.Not)
+		if err != nil {
+			return c, err
+		}
+		c.Not = &not
+	}
+	if c.Properties, err = newCachedSchemaMap(s.Properties); err != nil {
+		return c, err
+	}
+	if c.PatternProperties, err = newCachedSchemaMap(s.PatternProperties); err != nil {
+		return c, err
+	}
+	if c.Definitions, err = newCachedSchemaMap(s.Definitions); err != nil {
+		return c, err
+	}
+	if c.AdditionalProperties, err = newCachedSchemaOrBool(s.AdditionalProperties); err != nil {
+		return c, err
+	}
+	if c.AdditionalItems, err = newCachedSchemaOrBool(s.AdditionalItems); err != nil {
+		return c, err
+	}
+	if s.Dependencies != nil {
+		c.Dependencies = make(map[string]cachedDependency)
+		for k, v := range s.Dependencies {
+			d := cachedDependency{Property: v.Property}
+			if v.Schema != nil {
+				schema, err := newCachedSchema(*v.Schema)
+				if err != nil {
+					return c, err
+				}
+				d.Schema = &schema
+			}
+			c.Dependencies[k] = d
+		}
+	}
+	return c, nil
+}
+
+// Returns the spec.Schema the cached schema was made from
+func (c cachedSchema) schema() (spec.Schema, error) {
+	s := spec.Schema{}
+	s.ID = c.ID
+	s.Schema = spec.SchemaURL(c.Schema)
+	s.Description = c.Description
+	s.Type = c.Type
+	s.Format = c.Format
+	s.Title = c.Title
+	s.Maximum = c.Maximum.pointer()
+	s.ExclusiveMaximum = c.ExclusiveMaximum
+	s.Minimum = c.Minimum.pointer()
+	s.ExclusiveMinimum = c.ExclusiveMinimum
+	s.MaxLength = c.MaxLength.pointer()
+	s.MinLength = c.MinLength.pointer()
+	s.Pattern = c.Pattern
+	s.MaxItems = c.MaxItems.pointer()
+	s.MinItems = c.MinItems.pointer()
+	s.UniqueItems = c.UniqueItems
+	s.MultipleOf = c.MultipleOf.pointer()
+	s.MaxProperties = c.MaxProperties.pointer()
+	s.MinProperties = c.MinProperties.pointer()
+	s.Required = c.Required
+	s.Discriminator = c.Discriminator
+	s.ReadOnly = c.ReadOnly
+
+	var err error
+	if c.Ref != "" {
+		if s.Ref, err = spec.NewRef(c.Ref); err != nil {
+			return s, err
+		}
+	}
+	for _, v := range []struct {
+		data []byte
+		dst  interface{}
+	}{
+		{c.Default, &s.Default},
+		{c.Enum, &s.Enum},
+		{c.XML, &s.XML},
+		{c.ExternalDocs, &s.ExternalDocs},
+		{c.Example, &s.Example},
+		{c.Extensions, &s.Extensions},
+		{c.ExtraProps, &s.ExtraProps},
+	} {
+		if v.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(v.data, v.dst); err != nil {
+			return s, err
+		}
+	}
+
+	if c.Items != nil || c.ItemsList != nil {
+		s.Items = &spec.SchemaOrArray{}
+		if c.Items != nil {
+			item, err := c.Items.schema()
+			if err != nil {
+				return s, err
+			}
+			s.Items.Schema = &item
+		}
+		if s.Items.Schemas, err = cachedSchemas(c.ItemsList); err != nil {
+			return s, err
+		}
+	}
+	if s.AllOf, err = cachedSchemas(c.AllOf); err != nil {
+		return s, err
+	}
+	if s.OneOf, err = cachedSchemas(c.OneOf); err != nil {
+		return s, err
+	}
+	if s.AnyOf, err = cachedSchemas(c.AnyOf); err != nil {
+		return s, err
+	}
+	if c.Not != nil {
+		not, err := c.Not.schema()
+		if err != nil {
+			return s, err
+		}
+		s.Not = &not
+	}
+	if s.Properties, err = cachedSchemaMap(c.Properties); err != nil {
+		return s, err
+	}
+	if s.PatternProperties, err = cachedSchemaMap(c.PatternProperties); err != nil {
+		return s, err
+	}
+	if s.Definitions, err = cachedSchemaMap(c.Definitions); err != nil {
+		return s, err
+	}
+	if s.AdditionalProperties, err = c.AdditionalProperties.schemaOrBool(); err != nil {
+		return s, err
+	}
+	if s.AdditionalItems, err = c.AdditionalItems.schemaOrBool(); err != nil {
+		return s, err
+	}
+	if c.Dependencies != nil {
+		s.Dependencies = make(spec.Dependencies)
+		for k, v := range c.Dependencies {
+			d := spec.SchemaOrStringArray{Property: v.Property}
+			if v.Schema != nil {
+				schema, err := v.Schema.schema()
+				if err != nil {
+					return s, err
+				}
+				d.Schema = &schema
+			}
+			s.Dependencies[k] = d
+		}
+	}
+	return s, nil
+}
+
+func newCachedSchemas(list []spec.Schema) ([]cachedSchema, error) {
+	if list == nil {
+		return nil, nil
+	}
+	cached := make([]cachedSchema, len(list))
+	for i, s := range list {
+		var err error
+		if cached[i], err = newCachedSchema(s); err != nil {
+			return nil, err
+		}
+	}
+	return cached, nil
+}
+
+func cachedSchemas(cached []cachedSchema) ([]spec.Schema, error) {
+	if cached == nil {
+		return nil, nil
+	}
+	list := make([]spec.Schema, len(cached))
+	for i, c := range cached {
+		var err error
+		if list[i], err = c.schema(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func newCachedSchemaMap(m map[string]spec.Schema) (map[string]cachedSchema, error) {
+	if m == nil {
+		return nil, nil
+	}
+	cached := make(map[string]cachedSchema, len(m))
+	for k, s := range m {
+		var err error
+		if cached[k], err = newCachedSchema(s); err != nil {
+			return nil, err
+		}
+	}
+	return cached, nil
+}
+
+func cachedSchemaMap(cached map[string]cachedSchema) (map[string]spec.Schema, error) {
+	if cached == nil {
+		return nil, nil
+	}
+	m := make(map[string]spec.Schema, len(cached))
+	for k, c := range cached {
+		var err error
+		if m[k], err = c.schema(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func newCachedSchemaOrBool(s *spec.SchemaOrBool) (*cachedSchemaOrBool, error) {
+	if s == nil {
+		return nil, nil
+	}
+	c := &cachedSchemaOrBool{Allows: s.Allows}
+	if s.Schema != nil {
+		schema, err := newCachedSchema(*s.Schema)
+		if err != nil {
+			return nil, err
+		}
+		c.Schema = &schema
+	}
+	return c, nil
+}
+
+func (c *cachedSchemaOrBool) schemaOrBool() (*spec.SchemaOrBool, error) {
+	if c == nil {
+		return nil, nil
+	}
+	s := &spec.SchemaOrBool{Allows: c.Allows}
+	if c.Schema != nil {
+		schema, err := c.Schema.schema()
+		if err != nil {
+			return nil, err
+		}
+		s.Schema = &schema
+	}
+	return s, nil
+}
+
+func newCachedFloat(f *float64) cachedFloat {
+	if f == nil {
+		return cachedFloat{}
+	}
+	return cachedFloat{Set: true, Value: *f}
+}
+
+func (c cachedFloat) pointer() *float64 {
+	if !c.Set {
+		return nil
+	}
+	v := c.Value
+	return &v
+}
+
+func newCachedInt(i *int64) cachedInt {
+	if i == nil {
+		return cachedInt{}
+	}
+	return cachedInt{Set: true, Value: *i}
+}
+
+func (c cachedInt) pointer() *int64 {
+	if !c.Set {
+		return nil
+	}
+	v := c.Value
+	return &v
+}
+
+// Returns the value as JSON, nil for values that are not set
+func marshalCached(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		if t == nil {
+			return nil, nil
+		}
+	case *spec.XMLObject:
+		if t == nil {
+			return nil, nil
+		}
+	case *spec.ExternalDocumentation:
+		if t == nil {
+			return nil, nil
+		}
+	case spec.Extensions:
+		if t == nil {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		if t == nil {
+			return nil, nil
+		}
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+// Schema with every keyword spec.Schema has, including the ones gob would
+// lose without care like a minimum of 0
+const testCachedSchema = `{
+	"id": "http://example.com/probe",
+	"$schema": "http://json-schema.org/draft-04/schema#",
+	"description": "probe",
+	"type": ["object", "null"],
+	"format": "probe",
+	"title": "Probe",
+	"default": {"port": 80},
+	"maximum": 10,
+	"exclusiveMaximum": true,
+	"minimum": 0,
+	"exclusiveMinimum": true,
+	"maxLength": 0,
+	"minLength": 1,
+	"pattern": "^a",
+	"maxItems": 0,
+	"minItems": 2,
+	"uniqueItems": true,
+	"multipleOf": 0.5,
+	"enum": [null, 1, "a", {"b": [true]}],
+	"maxProperties": 0,
+	"minProperties": 1,
+	"required": ["port"],
+	"items": [{"type": "string"}, {"$ref": "#/definitions/io.k8s.Port"}],
+	"allOf": [{"$ref": "#/definitions/io.k8s.Handler"}],
+	"oneOf": [{"required": ["port"]}, {"required": ["name"]}],
+	"anyOf": [{"type": "object"}],
+	"not": {"type": "string"},
+	"properties": {
+		"port": {"type": "integer", "format": "int32", "minimum": 0},
+		"name": {"type": "array", "items": {"type": "string"}}
+	},
+	"additionalProperties": false,
+	"patternProperties": {"^x-": {"type": "string"}},
+	"dependencies": {"port": ["name"], "name": {"required": ["port"]}},
+	"additionalItems": {"type": "boolean"},
+	"definitions": {"local": {"type": "string"}},
+	"discriminator": "kind",
+	"readOnly": true,
+	"xml": {"name": "probe", "attribute": true},
+	"externalDocs": {"url": "http://example.com"},
+	"example": {"port": 8080},
+	"x-kubernetes-patch-strategy": "merge",
+	"$comment": "kept in ExtraProps"
+}`
+
+func TestCachedSchemaFields(t *testing.T) {
+	cached := reflect.TypeOf(cachedSchema{})
+	var fields []reflect.StructField
+	for _, typ := range []reflect.Type{reflect.TypeOf(spec.SchemaProps{}), reflect.TypeOf(spec.SwaggerSchemaProps{})} {
+		for i := 0; i < typ.NumField(); i++ {
+			fields = append(fields, typ.Field(i))
+		}
+	}
+	fields = append(fields, reflect.StructField{Name: "Extensions"}, reflect.StructField{Name: "ExtraProps"})
+
+	// a field spec.Schema gets in a new version would be lost by the cache
+	for _, f := range fields {
+		if _, ok := cached.FieldByName(f.Name); !ok {
+			t.Errorf("field %q of spec.Schema is not cached", f.Name)
+		}
+	}
+}
+
+func TestCacheEntryRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api, err := unmarshalSwagger([]byte(`{
+		"swagger": "2.0",
+		"info": {"title": "Kubernetes", "version": "v1.7.0"},
+		"paths": {},
+		"definitions": {"io.k8s.Probe": ` + testCachedSchema + `}
+	}`))
+	if err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	filename := filepath.Join(dir, "entry.gob")
+	if err := writeCacheEntry(filename, api); err != nil {
+		t.Fatalf("could not write cache entry: %v", err)
+	}
+	cached, err := readCacheEntry(filename)
+	if err != nil {
+		t.Fatalf("could not read cache entry: %v", err)
+	}
+
+	expected, err := json.Marshal(api)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, "cached schema", string(expected), cached)
+	if !reflect.DeepEqual(cached.Definitions["io.k8s.Probe"].Enum, api.Definitions["io.k8s.Probe"].Enum) {
+		t.Errorf("expected enum %v, got %v", api.Definitions["io.k8s.Probe"].Enum, cached.Definitions["io.k8s.Probe"].Enum)
+	}
+}
+
+func TestSchemaCacheParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := NewSchemaCache(dir)
+	src := Source{Name: "swagger.json", Content: []byte(`{
+		"swagger": "2.0",
+		"info": {"title": "Kubernetes", "version": "v1.7.0"},
+		"paths": {},
+		"definitions": {"io.k8s.Probe": {"type": "object", "properties": {"port": {"type": "integer", "minimum": 0}}}}
+	}`)}
+	expected := `{"io.k8s.Probe": {"type": "object", "properties": {"port": {"type": "integer", "minimum": 0}}}}`
+
+	api, err := c.Parse(src)
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+	assertJSONEqual(t, "parsed", expected, api.Definitions)
+	entry := c.entry(src.Content)
+	if _, err := os.Stat(entry); err != nil {
+		t.Fatalf("expected the schema to be cached: %v", err)
+	}
+
+	// read from the cache
+	api, err = c.Parse(src)
+	if err != nil {
+		t.Fatalf("could not parse cached schema: %v", err)
+	}
+	assertJSONEqual(t, "cached", expected, api.Definitions)
+
+	// broken entries are parsed again
+	if err := ioutil.WriteFile(entry, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api, err = c.Parse(src)
+	if err != nil {
+		t.Fatalf("could not parse schema with broken cache entry: %v", err)
+	}
+	assertJSONEqual(t, "broken entry", expected, api.Definitions)
+}
+
+func TestSchemaCacheEntryBuild(t *testing.T) {
+	c := NewSchemaCache("cache")
+	content := []byte(`{"swagger": "2.0"}`)
+	entry := c.entry(content)
+	if build := cacheBuild(); build == Version || len(build) != 64 {
+		t.Errorf("expected entries to be keyed on the hash of the binary, got %q", build)
+	}
+
+	// a rebuilt binary may parse the same content differently
+	build := buildHash
+	buildHash = "rebuilt"
+	defer func() { buildHash = build }()
+	if rebuilt := c.entry(content); rebuilt == entry {
+		t.Errorf("expected another binary to use another entry than %q", entry)
+	}
+}
+
+// Compares reading the cache entry of a schema as big as the one of
+// Kubernetes with parsing the schema itself, which the cache is for
+func BenchmarkSchemaCache(b *testing.B) {
+	defs := make(map[string]json.RawMessage)
+	for i := 0; i < 1000; i++ {
+		defs[fmt.Sprintf("io.k8s.api.v1.Definition%d", i)] = json.RawMessage(testCachedSchema)
+	}
+	content, err := json.Marshal(map[string]interface{}{
+		"swagger":     "2.0",
+		"info":        map[string]string{"title": "Kubernetes", "version": "v1.7.0"},
+		"paths":       map[string]interface{}{},
+		"definitions": defs,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "schemagen-cache")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	api, err := unmarshalSwagger(content)
+	if err != nil {
+		b.Fatal(err)
+	}
+	filename := filepath.Join(dir, "entry.gob")
+	if err := writeCacheEntry(filename, api); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := unmarshalSwagger(content); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := readCacheEntry(filename); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
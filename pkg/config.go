@@ -0,0 +1,282 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Name of the config file that is looked up in the working directory
+const ConfigFileName = "schemagen.yaml"
+
+// Config is the project configuration read from schemagen.yaml, it declares
+// all the inputs and outputs of generation and the rules applied in between
+type Config struct {
+	Kedge    KedgeConfig    `yaml:"kedge"`
+	Upstream UpstreamConfig `yaml:"upstream"`
+	// Aliases maps a definition key used in Kedge spec comments to the key
+	// that is actually defined upstream, useful when upstream renames its
+	// definitions e.g. between Kubernetes releases
+	Aliases map[string]string `yaml:"aliases"`
+	// Required overrides the list of required fields of definitions after
+	// the upstream definitions are injected into them
+	Required map[string]RequiredOverride `yaml:"required"`
+	Output   OutputConfig                `yaml:"output"`
+	// Diagnostics is given the problems that don't stop generation, it is
+	// only set from code
+	Diagnostics func(Diagnostic) `yaml:"-"`
+	// Cache upstream schemas are parsed through, it is only set from code
+	Cache *SchemaCache `yaml:"-"`
+}
+
+// KedgeConfig has the inputs that define Kedge spec
+type KedgeConfig struct {
+	// Go files or package directories that have Kedge spec structs
+	Sources []string `yaml:"sources"`
+}
+
+// UpstreamConfig has the upstream OpenAPI schemas that are injected
+// into Kedge definitions
+type UpstreamConfig struct {
+	Kubernetes string `yaml:"kubernetes"`
+	OpenShift  string `yaml:"openshift"`
+	// When given, schema is generated for each of these Kubernetes
+	// releases instead of 'kubernetes'
+	Releases []Release `yaml:"releases"`
+}
+
+// RequiredOverride changes the list of required fields of a definition,
+// fields are removed first and then added
+type RequiredOverride struct {
+	Add    []string `yaml:"add"`
+	Remove []string `yaml:"remove"`
+}
+
+// OutputConfig decides what is written where
+type OutputConfig struct {
+	// Format of the generated schema, one of OutputFormats
+	Format string `yaml:"format"`
+	// File to write the generated schema to, standard output if not given
+	File string `yaml:"file"`
+	// Directory to write the schema of every Kubernetes release to,
+	// needed when releases are given
+	ReleasesDir string `yaml:"releasesDir"`
+	// Directory to write every definition to in its own file, with an
+	// index of them
+	OutDir string `yaml:"outDir"`
+	// Also write all the definitions to a single file in OutDir
+	Bundle bool `yaml:"bundle"`
+	// Draft of JSON Schema the files in OutDir are written in, one
+	// of Drafts, if not given the definitions are written as they are
+	Draft string `yaml:"draft"`
+	// URL the files in OutDir are published under, every file gets
+	// its URL as identifier
+	IDBase string `yaml:"idBase"`
+	// Only output definitions reachable from roots
+	Prune bool `yaml:"prune"`
+	// Definition keys to start pruning from, implies prune
+	Roots []string `yaml:"roots"`
+	// Check compares the output files with what would be written instead
+	// of writing them, it is only set from command line
+	Check bool `yaml:"-"`
+}
+
+// Kubernetes and OpenShift definitions have some required fields which
+// Kedge fills in itself, so they are not required in Kedge files
+var DefaultRequiredOverrides = map[string]RequiredOverride{
+	"io.kedge.DeploymentSpecMod":       {Remove: []string{"template"}},
+	"io.kedge.DeploymentConfigSpecMod": {Remove: []string{"template"}},
+	"io.kedge.JobSpecMod":              {Remove: []string{"template"}},
+	"io.kedge.ContainerSpec":           {Remove: []string{"name"}},
+}
+
+// Returns the config used when there is no config file
+func DefaultConfig() *Config {
+	return &Config{
+		Kedge: KedgeConfig{
+			Sources: []string{"types.go"},
+		},
+		Upstream: UpstreamConfig{
+			Kubernetes: "swagger.json",
+			OpenShift:  "osv2.json",
+		},
+		Output: OutputConfig{
+			Format: FormatJSON,
+		},
+	}
+}
+
+// Returns the location of schemagen.yaml in the working directory, blank
+// if there is none
+func FindConfig() string {
+	if _, err := os.Stat(ConfigFileName); err == nil {
+		return ConfigFileName
+	}
+	return ""
+}
+
+// Reads the config file and returns it on top of the default config,
+// relative paths in the file are relative to the directory of the file.
+// It is an error if the file has any key that is not known. The returned
+// config should be validated once everything is overridden.
+func LoadConfig(filename string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read config file %q", filename)
+	}
+
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "could not parse config file %q", filename)
+	}
+	c.resolvePaths(filepath.Dir(filename))
+
+	cfg := DefaultConfig()
+	cfg.merge(c)
+	return cfg, nil
+}
+
+// Checks the values which can't be checked while parsing, including the
+// output options, which only matter when the schema is generated
+func (c *Config) Validate() error {
+	if err := c.ValidateInputs(); err != nil {
+		return err
+	}
+	if err := CheckFormat(c.Output.Format); err != nil {
+		return err
+	}
+	if len(c.Upstream.Releases) > 0 && c.Output.ReleasesDir == "" {
+		return fmt.Errorf("releases directory is needed when Kubernetes releases are given")
+	}
+	if c.Output.OutDir != "" && len(c.Upstream.Releases) > 0 {
+		return fmt.Errorf("definitions can't be written to their own files when Kubernetes releases are given")
+	}
+	if c.Output.Bundle && c.Output.OutDir == "" {
+		return fmt.Errorf("definition directory is needed to write the bundle to")
+	}
+	if err := CheckDraft(c.Output.Draft); err != nil {
+		return err
+	}
+	if (c.Output.Draft != "" || c.Output.IDBase != "") && c.Output.OutDir == "" {
+		return fmt.Errorf("JSON Schema draft and identifiers are only written to the files in definition directory, the OpenAPI schema has none")
+	}
+	if c.Output.IDBase != "" && c.Output.Draft == "" {
+		return fmt.Errorf("JSON Schema draft is needed to write identifiers")
+	}
+	if c.Output.Check && c.Output.File == "" && c.Output.OutDir == "" && len(c.Upstream.Releases) == 0 {
+		return fmt.Errorf("output file is needed to check the output against")
+	}
+	return nil
+}
+
+// Checks the inputs, which are needed whatever is done with the output,
+// commands that only read the definitions check nothing else
+func (c *Config) ValidateInputs() error {
+	if len(c.Kedge.Sources) == 0 {
+		return fmt.Errorf("no Kedge sources given")
+	}
+	for _, r := range c.Upstream.Releases {
+		if _, err := ParseRelease(r.Version + "=" + r.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if only reachable definitions should be written
+func (c *Config) Pruning() bool {
+	return c.Output.Prune || len(c.Output.Roots) > 0
+}
+
+// Makes relative paths relative to the given directory
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for i := range c.Kedge.Sources {
+		resolve(&c.Kedge.Sources[i])
+	}
+	resolve(&c.Upstream.Kubernetes)
+	resolve(&c.Upstream.OpenShift)
+	for i := range c.Upstream.Releases {
+		resolve(&c.Upstream.Releases[i].Schema)
+	}
+	resolve(&c.Output.File)
+	resolve(&c.Output.ReleasesDir)
+	resolve(&c.Output.OutDir)
+}
+
+// Overwrites values of c with the ones that are given in o
+func (c *Config) merge(o *Config) {
+	if len(o.Kedge.Sources) > 0 {
+		c.Kedge.Sources = o.Kedge.Sources
+	}
+	if o.Upstream.Kubernetes != "" {
+		c.Upstream.Kubernetes = o.Upstream.Kubernetes
+	}
+	if o.Upstream.OpenShift != "" {
+		c.Upstream.OpenShift = o.Upstream.OpenShift
+	}
+	if len(o.Upstream.Releases) > 0 {
+		c.Upstream.Releases = o.Upstream.Releases
+	}
+	if o.Aliases != nil {
+		c.Aliases = o.Aliases
+	}
+	if o.Required != nil {
+		c.Required = o.Required
+	}
+	if o.Output.Format != "" {
+		c.Output.Format = o.Output.Format
+	}
+	if o.Output.File != "" {
+		c.Output.File = o.Output.File
+	}
+	if o.Output.ReleasesDir != "" {
+		c.Output.ReleasesDir = o.Output.ReleasesDir
+	}
+	if o.Output.OutDir != "" {
+		c.Output.OutDir = o.Output.OutDir
+	}
+	if o.Output.Bundle {
+		c.Output.Bundle = true
+	}
+	if o.Output.Draft != "" {
+		c.Output.Draft = o.Output.Draft
+	}
+	if o.Output.IDBase != "" {
+		c.Output.IDBase = o.Output.IDBase
+	}
+	if o.Output.Prune {
+		c.Output.Prune = true
+	}
+	if len(o.Output.Roots) > 0 {
+		c.Output.Roots = o.Output.Roots
+	}
+}
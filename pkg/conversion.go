@@ -17,63 +17,135 @@ limitations under the License.
 package pkg
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"os"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/go-openapi/spec"
-	"k8s.io/apimachinery/pkg/openapi"
 )
 
-func ParseOpenAPIDefinition(filename string) (*openapi.OpenAPIDefinition, error) {
-
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read file %q: %v\n", filename, err)
+// Adds the definitions of src to target, replacing the ones with same keys
+func MergeDefinitions(target, src *spec.Swagger) {
+	for k, v := range src.Definitions {
+		target.Definitions[k] = v
 	}
+}
 
-	api := &openapi.OpenAPIDefinition{}
-	err = json.Unmarshal(content, &api.Schema)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling in OpenAPI definition: %v", err)
+// Returns the options to generate the schema as given in config
+func (c *Config) GeneratorOptions() GeneratorOptions {
+	opts := GeneratorOptions{
+		Kubernetes:  FileSource(c.Upstream.Kubernetes),
+		Aliases:     c.Aliases,
+		Required:    c.Required,
+		Prune:       c.Pruning(),
+		Roots:       c.Output.Roots,
+		Diagnostics: c.Diagnostics,
+		Cache:       c.Cache,
+	}
+	for _, s := range c.Kedge.Sources {
+		opts.Kedge = append(opts.Kedge, FileSource(s))
 	}
-	return api, nil
+	if c.Upstream.OpenShift != "" {
+		opts.OpenShift = FileSource(c.Upstream.OpenShift)
+	}
+	return opts
 }
 
-func MergeDefinitions(target, src *openapi.OpenAPIDefinition) {
-	for k, v := range src.Schema.SchemaProps.Definitions {
-		target.Schema.SchemaProps.Definitions[k] = v
+// Generates the OpenAPI schema for Kedge as given in config
+func GenerateOpenAPI(cfg *Config) (*spec.Swagger, error) {
+	g, err := NewGenerator(cfg.GeneratorOptions())
+	if err != nil {
+		return nil, err
 	}
+	return g.Generate()
 }
 
-func Conversion(KedgeSpecLocation, KubernetesSchema, OpenShiftSchema string) error {
-	defs, mapping, err := GenerateOpenAPIDefinitions(KedgeSpecLocation)
+// Generates the OpenAPI schema for Kedge and writes it in the output format
+// to the output file and every definition to its own file in the definition
+// directory, or prints it if neither is given. If pruning is asked for then
+// only the definitions reachable from roots are written, roots default to
+// all the Kedge definitions. When only checking, DriftError is returned if
+// the output files are not up to date.
+func Conversion(cfg *Config) error {
+	g, err := NewGenerator(cfg.GeneratorOptions())
 	if err != nil {
 		return err
 	}
-
-	k8sApi, err := ParseOpenAPIDefinition(KubernetesSchema)
+	api, err := g.Generate()
 	if err != nil {
-		return fmt.Errorf("kubernetes: %v", err)
+		return err
 	}
+	return writeGenerated(api, cfg.Output)
+}
 
-	osApi, err := ParseOpenAPIDefinition(OpenShiftSchema)
-	if err != nil {
-		return fmt.Errorf("openshift: %v", err)
+// Writes the generated schema to the output file and the definition
+// directory of out, or prints it if neither is given
+func writeGenerated(api *spec.Swagger, out OutputConfig) error {
+	if out.File == "" && out.OutDir == "" {
+		return Write(os.Stdout, api, out.Format)
+	}
+	drift := &DriftError{}
+	if out.File != "" {
+		if err := writeOutput(out.File, api, out.Format, out.Check, drift); err != nil {
+			return err
+		}
+	}
+	if out.OutDir != "" {
+		if err := writeSplit(api.Definitions, out, drift); err != nil {
+			return err
+		}
 	}
+	if len(drift.Files) > 0 {
+		return drift
+	}
+	return nil
+}
 
-	MergeDefinitions(k8sApi, osApi)
-	api := k8sApi
+// Replaces the aliases used in the Kedge spec comments with the actual
+// definition keys, both in references of the Kedge definitions and in the
+// sources of injections
+func ApplyAliases(defs spec.Definitions, mappings []Injection, aliases map[string]string) {
+	if len(aliases) == 0 {
+		return
+	}
+	for i, m := range mappings {
+		if key, ok := aliases[m.Source]; ok {
+			mappings[i].Source = key
+		}
+	}
+	for k, v := range defs {
+		defs[k] = rewriteRefs(v, aliases)
+	}
+}
 
-	defs = InjectKedgeSpec(api.Schema.SchemaProps.Definitions, defs, mapping)
+// Returns copy of the schema where references to the keys of aliases
+// are replaced with references to their values, in the schema and every
+// schema nested inside of it
+func rewriteRefs(s spec.Schema, aliases map[string]string) spec.Schema {
+	return mapSchema(s, func(s spec.Schema) spec.Schema {
+		if key, ok := aliases[RefKey(s.Ref)]; ok {
+			s.Ref = spec.MustCreateRef(definitionsRefPrefix + key)
+		}
+		return s
+	})
+}
 
-	// add defs to openapi
-	for k, v := range defs {
-		api.Schema.SchemaProps.Definitions[k] = v
+// Removes and then adds the required fields of definitions as given in
+// overrides, definitions that are not found are ignored
+func ApplyRequiredOverrides(defs spec.Definitions, overrides map[string]RequiredOverride) {
+	for key, o := range overrides {
+		def, ok := defs[key]
+		if !ok {
+			continue
+		}
+		remove := stringSet(o.Remove)
+		var required []string
+		for _, r := range def.Required {
+			if !remove[r] {
+				required = append(required, r)
+			}
+		}
+		def.Required = AddListUniqueItems(required, o.Add)
+		defs[key] = def
 	}
-	PrintJSONStdOut(api.Schema)
-	return nil
 }
 
 func augmentProperties(s, t spec.Schema) spec.Schema {
@@ -89,40 +161,6 @@ func augmentProperties(s, t spec.Schema) spec.Schema {
 func InjectKedgeSpec(koDefinitions spec.Definitions, kedgeDefinitions spec.Definitions, mappings []Injection) spec.Definitions {
 	for _, m := range mappings {
 		kedgeDefinitions[m.Target] = augmentProperties(koDefinitions[m.Source], kedgeDefinitions[m.Target])
-
-		switch m.Target {
-		// special case, where if the key is io.kedge.DeploymentSpec
-		// ignore the required field called template
-		case "io.kedge.DeploymentSpecMod",
-			"io.kedge.DeploymentConfigSpecMod",
-			"io.kedge.JobSpecMod":
-			v := kedgeDefinitions[m.Target]
-			var final []string
-			for _, r := range v.Required {
-				if r != "template" {
-					final = append(final, r)
-				}
-			}
-			v.Required = final
-			kedgeDefinitions[m.Target] = v
-		case "io.kedge.ContainerSpec":
-			containerDef := kedgeDefinitions[m.Target]
-			for i, r := range containerDef.Required {
-				if r == "name" {
-					containerDef.Required[i] = containerDef.Required[len(containerDef.Required)-1]
-					containerDef.Required = containerDef.Required[:len(containerDef.Required)-1]
-				}
-			}
-			kedgeDefinitions[m.Target] = containerDef
-		}
 	}
 	return kedgeDefinitions
 }
-
-func PrintJSONStdOut(v interface{}) {
-	b, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		log.Fatalln(err)
-	}
-	fmt.Println(string(b))
-}
@@ -0,0 +1,116 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"testing"
+)
+
+func TestApplyAliases(t *testing.T) {
+	aliases := map[string]string{"old.Probe": "io.k8s.api.core.v1.Probe"}
+	tests := []struct {
+		name     string
+		schema   string
+		expected string
+	}{
+		{
+			"ref",
+			`{"$ref": "#/definitions/old.Probe"}`,
+			`{"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}`,
+		},
+		{
+			"not aliased",
+			`{"$ref": "#/definitions/io.kedge.Other"}`,
+			`{"$ref": "#/definitions/io.kedge.Other"}`,
+		},
+		{
+			"properties and items",
+			`{"properties": {"probes": {"type": "array", "items": {"$ref": "#/definitions/old.Probe"}}}}`,
+			`{"properties": {"probes": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}}}}`,
+		},
+		{
+			"tuple items",
+			`{"type": "array", "items": [{"type": "string"}, {"$ref": "#/definitions/old.Probe"}]}`,
+			`{"type": "array", "items": [{"type": "string"}, {"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}]}`,
+		},
+		{
+			"additional properties",
+			`{"type": "object", "additionalProperties": {"$ref": "#/definitions/old.Probe"}}`,
+			`{"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}}`,
+		},
+		{
+			"allOf and oneOf",
+			`{"allOf": [{"$ref": "#/definitions/old.Probe"}], "oneOf": [{"type": "null"}, {"$ref": "#/definitions/old.Probe"}]}`,
+			`{"allOf": [{"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}], "oneOf": [{"type": "null"}, {"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}]}`,
+		},
+		{
+			"not",
+			`{"not": {"$ref": "#/definitions/old.Probe"}}`,
+			`{"not": {"$ref": "#/definitions/io.k8s.api.core.v1.Probe"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		defs := testDefinitions(t, `{"io.kedge.Test": `+test.schema+`}`)
+		ApplyAliases(defs, nil, aliases)
+		assertJSONEqual(t, test.name, test.expected, defs["io.kedge.Test"])
+	}
+
+	mappings := []Injection{{Target: "io.kedge.Test", Source: "old.Probe"}}
+	ApplyAliases(testDefinitions(t, `{}`), mappings, aliases)
+	if mappings[0].Source != "io.k8s.api.core.v1.Probe" {
+		t.Errorf("expected source of injection to be replaced, got %q", mappings[0].Source)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		// changes the default config
+		change func(c *Config)
+		// error of Validate, ValidateInputs only fails for the inputs
+		invalidOutput bool
+		invalidInputs bool
+	}{
+		{"default", func(c *Config) {}, false, false},
+		{"no kedge sources", func(c *Config) { c.Kedge.Sources = nil }, true, true},
+		{
+			"releases without directory",
+			func(c *Config) { c.Upstream.Releases = []Release{{Version: "1.7", Schema: "swagger.json"}} },
+			true, false,
+		},
+		{"bundle without definition directory", func(c *Config) { c.Output.Bundle = true }, true, false},
+		{"unknown format", func(c *Config) { c.Output.Format = "xml" }, true, false},
+		{"check without output", func(c *Config) { c.Output.Check = true }, true, false},
+		{
+			"draft with definition directory",
+			func(c *Config) { c.Output.Draft, c.Output.OutDir = "7", "out" },
+			false, false,
+		},
+	}
+
+	for _, test := range tests {
+		c := DefaultConfig()
+		test.change(c)
+		if err := c.Validate(); (err != nil) != test.invalidOutput {
+			t.Errorf("%s: expected Validate to fail to be %v, got %v", test.name, test.invalidOutput, err)
+		}
+		if err := c.ValidateInputs(); (err != nil) != test.invalidInputs {
+			t.Errorf("%s: expected ValidateInputs to fail to be %v, got %v", test.name, test.invalidInputs, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Severities of diagnostics
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Names of the rules of diagnostics found outside of lint
+const (
+	RuleSourceError = "source-error"
+	RuleParseError  = "parse-error"
+	RuleInjection   = "injection"
+	RuleValidation  = "validation"
+	RuleDanglingRef = "dangling-ref"
+	RuleAppReplaced = "app-replaced"
+)
+
+// Rule is a kind of problem diagnostics are reported for
+type Rule struct {
+	Name        string
+	Severity    string
+	Description string
+}
+
+// Rules of the problems found while generating and validating
+var GenerationRules = []Rule{
+	{RuleSourceError, SeverityError, "input files can be read and are of the right format"},
+	{RuleParseError, SeverityError, "Kedge spec source code can be parsed and converted to definitions"},
+	{RuleInjection, SeverityWarning, "embedded upstream types are found in upstream schemas so they can be injected"},
+	{RuleValidation, SeverityError, "Kedge files are valid against the schema"},
+	{RuleDanglingRef, SeverityWarning, "references in definitions kept when pruning point to definitions that are defined"},
+	{RuleAppReplaced, SeverityWarning, "Kedge spec does not define the root definition that is generated from the controllers"},
+}
+
+// Returns all the rules diagnostics can be reported for
+func AllRules() []Rule {
+	return append(append([]Rule{}, GenerationRules...), LintRules...)
+}
+
+// Returns the diagnostic for errors that point to a problem in an input
+// file, false if the error is of any other kind
+func ErrorDiagnostic(err error) (Diagnostic, bool) {
+	switch e := errors.Cause(err).(type) {
+	case *ParseError:
+		return Diagnostic{
+			Rule:     RuleParseError,
+			Severity: SeverityError,
+			Message:  e.Message,
+			File:     e.File,
+			Line:     e.Line,
+			Column:   e.Column,
+		}, true
+	case *SourceError:
+		return Diagnostic{
+			Rule:     RuleSourceError,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s: %v", e.Kind, e.Err),
+			File:     e.Name,
+		}, true
+	}
+	return Diagnostic{}, false
+}
+
+// Diagnostic is a problem found in one of the input files, pointing to the
+// position where it was found
+type Diagnostic struct {
+	// Identifier of the rule that found the problem e.g. 'duplicate-key'
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos = fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+	}
+	if pos != "" {
+		pos += ": "
+	}
+	return fmt.Sprintf("%s%s: %s [%s]", pos, d.Severity, d.Message, d.Rule)
+}
+
+// Returns true if any of the diagnostics is an error
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorts diagnostics by their position and then by rule
+func SortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Rule < b.Rule
+	})
+}
+
+// Writes the diagnostics one per line followed by a summary
+func WriteDiagnosticsText(w io.Writer, diags []Diagnostic) error {
+	errs := 0
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			errs++
+		}
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d errors, %d warnings\n", errs, len(diags)-errs)
+	return err
+}
@@ -0,0 +1,430 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-openapi/spec"
+)
+
+// Change is a single structural difference found between two schemas
+type Change struct {
+	// Path of the schema that has changed, starts with the definition key
+	// followed by property names, '[]' denotes items of an array, '[0]' the
+	// first item of a tuple, '{}' denotes values of a map and e.g.
+	// '.allOf[0]' the first schema of allOf
+	Path string `json:"path"`
+	// Short machine friendly identifier of the change e.g. 'property-removed'
+	Kind string `json:"kind"`
+	// Human readable explanation of the change
+	Message string `json:"message"`
+	// Breaking is set when documents that were valid against the old
+	// schema might not be valid against the new one
+	Breaking bool `json:"breaking"`
+}
+
+// SchemaDiff has all the changes found between two sets of definitions
+// sorted into breaking and non-breaking ones
+type SchemaDiff struct {
+	Breaking    []Change `json:"breaking"`
+	NonBreaking []Change `json:"nonBreaking"`
+}
+
+// Returns true if any of the changes is breaking
+func (d *SchemaDiff) HasBreaking() bool {
+	return len(d.Breaking) > 0
+}
+
+func (d *SchemaDiff) add(path, kind, message string, breaking bool) {
+	c := Change{Path: path, Kind: kind, Message: message, Breaking: breaking}
+	if breaking {
+		d.Breaking = append(d.Breaking, c)
+	} else {
+		d.NonBreaking = append(d.NonBreaking, c)
+	}
+}
+
+// Compares two sets of definitions structurally and returns all the
+// changes that were needed to get from old to new
+func DiffDefinitions(old, new spec.Definitions) *SchemaDiff {
+	d := &SchemaDiff{Breaking: []Change{}, NonBreaking: []Change{}}
+
+	for _, k := range sortedSchemaKeys(old) {
+		n, ok := new[k]
+		if !ok {
+			d.add(k, "definition-removed", "definition removed", true)
+			continue
+		}
+		d.diffSchema(k, old[k], n)
+	}
+	for _, k := range sortedSchemaKeys(new) {
+		if _, ok := old[k]; !ok {
+			d.add(k, "definition-added", "definition added", false)
+		}
+	}
+
+	for _, changes := range [][]Change{d.Breaking, d.NonBreaking} {
+		sort.SliceStable(changes, func(i, j int) bool {
+			if changes[i].Path != changes[j].Path {
+				return changes[i].Path < changes[j].Path
+			}
+			return changes[i].Kind < changes[j].Kind
+		})
+	}
+	return d
+}
+
+// DefinitionChanges lists the keys of definitions that are different
+// between two sets of definitions, each list is sorted
+type DefinitionChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// Returns true if no definition has changed
+func (c *DefinitionChanges) Empty() bool {
+	return len(c.Added)+len(c.Removed)+len(c.Changed) == 0
+}
+
+// Returns the keys of definitions that were added, removed or changed in
+// any way, including changes that don't change what is valid e.g. of
+// descriptions
+func ChangedDefinitions(old, new spec.Definitions) *DefinitionChanges {
+	c := &DefinitionChanges{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for _, k := range sortedSchemaKeys(old) {
+		n, ok := new[k]
+		if !ok {
+			c.Removed = append(c.Removed, k)
+			continue
+		}
+		o, _ := json.Marshal(old[k])
+		m, _ := json.Marshal(n)
+		if string(o) != string(m) {
+			c.Changed = append(c.Changed, k)
+		}
+	}
+	for _, k := range sortedSchemaKeys(new) {
+		if _, ok := old[k]; !ok {
+			c.Added = append(c.Added, k)
+		}
+	}
+	return c
+}
+
+// Compares the two given schemas found at path and records all the changes
+func (d *SchemaDiff) diffSchema(path string, old, new spec.Schema) {
+	// if either of them is reference, then the definition they refer to
+	// is compared separately, so only check if it is pointing elsewhere now
+	oldRef, newRef := old.Ref.String(), new.Ref.String()
+	if oldRef != newRef {
+		d.add(path, "ref-changed", fmt.Sprintf("reference changed from %q to %q", oldRef, newRef), true)
+		return
+	}
+	if oldRef != "" {
+		return
+	}
+
+	d.diffType(path, old.Type, new.Type)
+
+	if old.Format != new.Format {
+		// removing format only allows more values
+		d.add(path, "format-changed", fmt.Sprintf("format changed from %q to %q", old.Format, new.Format), new.Format != "")
+	}
+
+	d.diffEnum(path, old.Enum, new.Enum)
+
+	d.diffLimit(path, "minimum", old.Minimum, new.Minimum, true)
+	d.diffLimit(path, "maximum", old.Maximum, new.Maximum, false)
+	d.diffLimit(path, "minLength", intToFloat(old.MinLength), intToFloat(new.MinLength), true)
+	d.diffLimit(path, "maxLength", intToFloat(old.MaxLength), intToFloat(new.MaxLength), false)
+	d.diffLimit(path, "minItems", intToFloat(old.MinItems), intToFloat(new.MinItems), true)
+	d.diffLimit(path, "maxItems", intToFloat(old.MaxItems), intToFloat(new.MaxItems), false)
+
+	if old.Pattern != new.Pattern {
+		// pattern changes can't be compared, so unless it is removed
+		// it is assumed that new pattern can reject old values
+		d.add(path, "pattern-changed", fmt.Sprintf("pattern changed from %q to %q", old.Pattern, new.Pattern), new.Pattern != "")
+	}
+
+	// required fields
+	oldRequired, newRequired := stringSet(old.Required), stringSet(new.Required)
+	for _, r := range new.Required {
+		if !oldRequired[r] {
+			d.add(path+"."+r, "required-added", "field is now required", true)
+		}
+	}
+	for _, r := range old.Required {
+		if !newRequired[r] {
+			d.add(path+"."+r, "required-removed", "field is no longer required", false)
+		}
+	}
+
+	// properties
+	for _, k := range sortedSchemaKeys(old.Properties) {
+		n, ok := new.Properties[k]
+		if !ok {
+			d.add(path+"."+k, "property-removed", "property removed", true)
+			continue
+		}
+		d.diffSchema(path+"."+k, old.Properties[k], n)
+	}
+	for _, k := range sortedSchemaKeys(new.Properties) {
+		if _, ok := old.Properties[k]; !ok {
+			d.add(path+"."+k, "property-added", "property added", false)
+		}
+	}
+
+	// items of an array
+	d.diffItems(path, old.Items, new.Items)
+
+	// values have to match all the schemas of allOf, so more of them can
+	// only reject more values, and at least one of anyOf, so fewer of them
+	// can. Values have to match exactly one of oneOf, so any change can.
+	d.diffBranches(path, "allOf", old.AllOf, new.AllOf, true, false)
+	d.diffBranches(path, "anyOf", old.AnyOf, new.AnyOf, false, true)
+	d.diffBranches(path, "oneOf", old.OneOf, new.OneOf, true, true)
+
+	// values of a map, when not given any additional property is allowed
+	// just like with the schema {}, so giving a schema can reject values
+	oldValues, oldAllows := additionalSchema(old.AdditionalProperties)
+	newValues, newAllows := additionalSchema(new.AdditionalProperties)
+	switch {
+	case oldAllows && !newAllows:
+		d.add(path, "additional-properties-removed", "additional properties are no longer allowed", true)
+	case !oldAllows && newAllows:
+		d.add(path, "additional-properties-added", "additional properties are now allowed", false)
+	case !oldAllows:
+	case oldValues != nil && newValues == nil:
+		// a reference compared to {} would be reported as changed
+		d.add(path+"{}", "additional-properties-widened", "additional properties of any value are now allowed", false)
+	case oldValues != nil || newValues != nil:
+		if oldValues == nil {
+			oldValues = &spec.Schema{}
+		}
+		d.diffSchema(path+"{}", *oldValues, *newValues)
+	}
+}
+
+// Returns the schema of additional properties and whether they are allowed
+// at all, the schema is nil if any value is allowed
+func additionalSchema(a *spec.SchemaOrBool) (*spec.Schema, bool) {
+	switch {
+	case a == nil:
+		return nil, true
+	case a.Schema != nil:
+		return a.Schema, true
+	}
+	return nil, a.Allows
+}
+
+// Types are compared as sets of the values they allow, if new set is smaller
+// then it is narrowed and if bigger then widened, no type at all means any
+// type is allowed. Integers are numbers too, so changing integer to number
+// is widening.
+func (d *SchemaDiff) diffType(path string, old, new spec.StringOrArray) {
+	oldTypes, newTypes := stringSet(old), stringSet(new)
+	switch {
+	case len(old) == 0 && len(new) == 0:
+		return
+	case len(old) == 0:
+		d.add(path, "type-narrowed", fmt.Sprintf("type narrowed from any to %v", []string(new)), true)
+	case len(new) == 0:
+		d.add(path, "type-widened", fmt.Sprintf("type widened from %v to any", []string(old)), false)
+	case typesAllow(oldTypes, newTypes) && typesAllow(newTypes, oldTypes):
+		return
+	case typesAllow(oldTypes, newTypes):
+		d.add(path, "type-narrowed", fmt.Sprintf("type narrowed from %v to %v", []string(old), []string(new)), true)
+	case typesAllow(newTypes, oldTypes):
+		d.add(path, "type-widened", fmt.Sprintf("type widened from %v to %v", []string(old), []string(new)), false)
+	default:
+		d.add(path, "type-changed", fmt.Sprintf("type changed from %v to %v", []string(old), []string(new)), true)
+	}
+}
+
+// Returns true if the types a allow all the values of the types b
+func typesAllow(a, b map[string]bool) bool {
+	for t := range b {
+		if !a[t] && !(t == "integer" && a["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compares the items of arrays, which are given either by one schema for
+// all of them or by a schema for every item of a tuple
+func (d *SchemaDiff) diffItems(path string, old, new *spec.SchemaOrArray) {
+	oldAny := old == nil || old.Schema == nil && len(old.Schemas) == 0
+	newAny := new == nil || new.Schema == nil && len(new.Schemas) == 0
+	switch {
+	case oldAny && newAny:
+		return
+	case oldAny:
+		d.add(path+"[]", "items-added", "items are now restricted", true)
+	case newAny:
+		d.add(path+"[]", "items-removed", "items are no longer restricted", false)
+	case old.Schema != nil && new.Schema != nil:
+		d.diffSchema(path+"[]", *old.Schema, *new.Schema)
+	case old.Schema == nil && new.Schema == nil:
+		for i := 0; i < len(old.Schemas) && i < len(new.Schemas); i++ {
+			d.diffSchema(fmt.Sprintf("%s[%d]", path, i), old.Schemas[i], new.Schemas[i])
+		}
+		// items after the ones of the tuple can be anything
+		for i := len(new.Schemas); i < len(old.Schemas); i++ {
+			d.add(fmt.Sprintf("%s[%d]", path, i), "tuple-item-removed", "item is no longer restricted", false)
+		}
+		for i := len(old.Schemas); i < len(new.Schemas); i++ {
+			d.add(fmt.Sprintf("%s[%d]", path, i), "tuple-item-added", "item is now restricted", true)
+		}
+	default:
+		d.add(path+"[]", "items-changed", "items changed between a single schema and a tuple", true)
+	}
+}
+
+// Compares the schemas of allOf, anyOf or oneOf given by name in the same
+// order, added and removed say if adding or removing schemas is breaking
+func (d *SchemaDiff) diffBranches(path, name string, old, new []spec.Schema, added, removed bool) {
+	for i := 0; i < len(old) && i < len(new); i++ {
+		d.diffSchema(fmt.Sprintf("%s.%s[%d]", path, name, i), old[i], new[i])
+	}
+	for i := len(new); i < len(old); i++ {
+		d.add(fmt.Sprintf("%s.%s[%d]", path, name, i), "branch-removed", fmt.Sprintf("schema removed from %s", name), removed)
+	}
+	for i := len(old); i < len(new); i++ {
+		d.add(fmt.Sprintf("%s.%s[%d]", path, name, i), "branch-added", fmt.Sprintf("schema added to %s", name), added)
+	}
+}
+
+// Enums are compared as sets, removing a value or adding an enum where
+// there was none before is narrowing the allowed values
+func (d *SchemaDiff) diffEnum(path string, old, new []interface{}) {
+	switch {
+	case len(old) == 0 && len(new) == 0:
+		return
+	case len(old) == 0:
+		d.add(path, "enum-added", fmt.Sprintf("values restricted to %v", new), true)
+		return
+	case len(new) == 0:
+		d.add(path, "enum-removed", "values are no longer restricted", false)
+		return
+	}
+
+	oldValues, newValues := enumSet(old), enumSet(new)
+	for _, v := range old {
+		if !newValues[fmt.Sprint(v)] {
+			d.add(path, "enum-value-removed", fmt.Sprintf("enum value %v removed", v), true)
+		}
+	}
+	for _, v := range new {
+		if !oldValues[fmt.Sprint(v)] {
+			d.add(path, "enum-value-added", fmt.Sprintf("enum value %v added", v), false)
+		}
+	}
+}
+
+// Compares the numeric limits like minimum or maxLength, lower is set for
+// the limits where increasing value narrows the allowed values
+func (d *SchemaDiff) diffLimit(path, name string, old, new *float64, lower bool) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old == nil:
+		d.add(path, name+"-added", fmt.Sprintf("%s of %v added", name, *new), true)
+	case new == nil:
+		d.add(path, name+"-removed", fmt.Sprintf("%s of %v removed", name, *old), false)
+	case *old != *new:
+		narrowed := *new > *old
+		if !lower {
+			narrowed = !narrowed
+		}
+		d.add(path, name+"-changed", fmt.Sprintf("%s changed from %v to %v", name, *old, *new), narrowed)
+	}
+}
+
+// Writes the diff in human readable form
+func WriteDiffText(w io.Writer, d *SchemaDiff) error {
+	sections := []struct {
+		title   string
+		changes []Change
+	}{
+		{"Breaking changes", d.Breaking},
+		{"Non-breaking changes", d.NonBreaking},
+	}
+	for _, s := range sections {
+		if len(s.changes) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s:\n", s.title); err != nil {
+			return err
+		}
+		for _, c := range s.changes {
+			if _, err := fmt.Fprintf(w, "  %s: %s\n", c.Path, c.Message); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d breaking, %d non-breaking changes\n", len(d.Breaking), len(d.NonBreaking))
+	return err
+}
+
+// Writes the diff as JSON
+func WriteDiffJSON(w io.Writer, d *SchemaDiff) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func sortedSchemaKeys(m map[string]spec.Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringSet(list []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
+
+func enumSet(list []interface{}) map[string]bool {
+	set := make(map[string]bool)
+	for _, item := range list {
+		set[fmt.Sprint(item)] = true
+	}
+	return set
+}
+
+func intToFloat(i *int64) *float64 {
+	if i == nil {
+		return nil
+	}
+	f := float64(*i)
+	return &f
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		breaking []string
+		// non-breaking changes, both lists have '<path> <kind>'
+		nonBreaking []string
+	}{
+		{
+			"same",
+			`{"type": "object", "properties": {"port": {"type": "integer"}}}`,
+			`{"type": "object", "properties": {"port": {"type": "integer"}}}`,
+			nil, nil,
+		},
+		{
+			"integer to number",
+			`{"type": "integer"}`,
+			`{"type": "number"}`,
+			nil, []string{"io.kedge.Test type-widened"},
+		},
+		{
+			"number to integer",
+			`{"type": "number"}`,
+			`{"type": "integer"}`,
+			[]string{"io.kedge.Test type-narrowed"}, nil,
+		},
+		{
+			"integer and number to number",
+			`{"type": ["integer", "number"]}`,
+			`{"type": "number"}`,
+			nil, nil,
+		},
+		{
+			"string to integer",
+			`{"type": "string"}`,
+			`{"type": "integer"}`,
+			[]string{"io.kedge.Test type-changed"}, nil,
+		},
+		{
+			"type removed",
+			`{"type": "string"}`,
+			`{}`,
+			nil, []string{"io.kedge.Test type-widened"},
+		},
+		{
+			"properties",
+			`{"required": ["name"], "properties": {"name": {"type": "string"}, "port": {"type": "integer"}}}`,
+			`{"required": ["port"], "properties": {"port": {"type": "integer"}, "image": {"type": "string"}}}`,
+			[]string{"io.kedge.Test.name property-removed", "io.kedge.Test.port required-added"},
+			[]string{"io.kedge.Test.image property-added", "io.kedge.Test.name required-removed"},
+		},
+		{
+			"enum and limits",
+			`{"enum": ["a", "b"], "minLength": 1, "maximum": 10}`,
+			`{"enum": ["a", "c"], "minLength": 2}`,
+			[]string{"io.kedge.Test enum-value-removed", "io.kedge.Test minLength-changed"},
+			[]string{"io.kedge.Test enum-value-added", "io.kedge.Test maximum-removed"},
+		},
+		{
+			"ref changed",
+			`{"$ref": "#/definitions/io.kedge.A"}`,
+			`{"$ref": "#/definitions/io.kedge.B"}`,
+			[]string{"io.kedge.Test ref-changed"}, nil,
+		},
+		{
+			"items",
+			`{"type": "array", "items": {"type": "integer"}}`,
+			`{"type": "array", "items": {"type": "string"}}`,
+			[]string{"io.kedge.Test[] type-changed"}, nil,
+		},
+		{
+			"items added",
+			`{"type": "array"}`,
+			`{"type": "array", "items": {"type": "string"}}`,
+			[]string{"io.kedge.Test[] items-added"}, nil,
+		},
+		{
+			"tuple items",
+			`{"type": "array", "items": [{"type": "string"}, {"type": "integer"}]}`,
+			`{"type": "array", "items": [{"type": "string", "pattern": "^a"}]}`,
+			[]string{"io.kedge.Test[0] pattern-changed"},
+			[]string{"io.kedge.Test[1] tuple-item-removed"},
+		},
+		{
+			"tuple item added",
+			`{"type": "array", "items": [{"type": "string"}]}`,
+			`{"type": "array", "items": [{"type": "string"}, {"type": "integer"}]}`,
+			[]string{"io.kedge.Test[1] tuple-item-added"}, nil,
+		},
+		{
+			"items changed to tuple",
+			`{"type": "array", "items": {"type": "string"}}`,
+			`{"type": "array", "items": [{"type": "string"}]}`,
+			[]string{"io.kedge.Test[] items-changed"}, nil,
+		},
+		{
+			"map values",
+			`{"type": "object", "additionalProperties": {"type": "string"}}`,
+			`{"type": "object", "additionalProperties": {"type": "string", "maxLength": 10}}`,
+			[]string{"io.kedge.Test{} maxLength-added"}, nil,
+		},
+		{
+			"additional properties constrained",
+			`{"type": "object"}`,
+			`{"type": "object", "additionalProperties": {"type": "string"}}`,
+			[]string{"io.kedge.Test{} type-narrowed"}, nil,
+		},
+		{
+			"additional properties constrained to reference",
+			`{"type": "object", "additionalProperties": true}`,
+			`{"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.Probe"}}`,
+			[]string{"io.kedge.Test{} ref-changed"}, nil,
+		},
+		{
+			"additional properties of any value",
+			`{"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.Probe"}}`,
+			`{"type": "object"}`,
+			nil, []string{"io.kedge.Test{} additional-properties-widened"},
+		},
+		{
+			"additional properties removed",
+			`{"type": "object"}`,
+			`{"type": "object", "additionalProperties": false}`,
+			[]string{"io.kedge.Test additional-properties-removed"}, nil,
+		},
+		{
+			"allOf",
+			`{"allOf": [{"type": "object", "required": ["name"]}]}`,
+			`{"allOf": [{"type": "object"}, {"required": ["port"]}]}`,
+			[]string{"io.kedge.Test.allOf[1] branch-added"},
+			[]string{"io.kedge.Test.allOf[0].name required-removed"},
+		},
+		{
+			"allOf removed",
+			`{"allOf": [{"type": "object"}, {"required": ["port"]}]}`,
+			`{"allOf": [{"type": "object"}]}`,
+			nil, []string{"io.kedge.Test.allOf[1] branch-removed"},
+		},
+		{
+			"anyOf",
+			`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`,
+			`{"anyOf": [{"type": "string"}, {"type": "number"}, {"type": "boolean"}]}`,
+			nil, []string{"io.kedge.Test.anyOf[1] type-widened", "io.kedge.Test.anyOf[2] branch-added"},
+		},
+		{
+			"anyOf removed",
+			`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`,
+			`{"anyOf": [{"type": "string"}]}`,
+			[]string{"io.kedge.Test.anyOf[1] branch-removed"}, nil,
+		},
+		{
+			"oneOf",
+			`{"oneOf": [{"$ref": "#/definitions/io.kedge.A"}]}`,
+			`{"oneOf": [{"$ref": "#/definitions/io.kedge.B"}, {"$ref": "#/definitions/io.kedge.C"}]}`,
+			[]string{"io.kedge.Test.oneOf[0] ref-changed", "io.kedge.Test.oneOf[1] branch-added"}, nil,
+		},
+	}
+
+	for _, test := range tests {
+		old := testDefinitions(t, `{"io.kedge.Test": `+test.old+`}`)
+		new := testDefinitions(t, `{"io.kedge.Test": `+test.new+`}`)
+		d := DiffDefinitions(old, new)
+		if breaking := changeList(d.Breaking); !reflect.DeepEqual(breaking, test.breaking) {
+			t.Errorf("%s: expected breaking changes %v, got %v", test.name, test.breaking, breaking)
+		}
+		if nonBreaking := changeList(d.NonBreaking); !reflect.DeepEqual(nonBreaking, test.nonBreaking) {
+			t.Errorf("%s: expected non-breaking changes %v, got %v", test.name, test.nonBreaking, nonBreaking)
+		}
+		if d.HasBreaking() != (len(test.breaking) > 0) {
+			t.Errorf("%s: expected HasBreaking to be %v", test.name, len(test.breaking) > 0)
+		}
+	}
+}
+
+func TestDiffDefinitionsAddedRemoved(t *testing.T) {
+	old := testDefinitions(t, `{"io.kedge.A": {}, "io.kedge.B": {}}`)
+	new := testDefinitions(t, `{"io.kedge.B": {}, "io.kedge.C": {}}`)
+	d := DiffDefinitions(old, new)
+	if breaking := changeList(d.Breaking); !reflect.DeepEqual(breaking, []string{"io.kedge.A definition-removed"}) {
+		t.Errorf("unexpected breaking changes %v", breaking)
+	}
+	if nonBreaking := changeList(d.NonBreaking); !reflect.DeepEqual(nonBreaking, []string{"io.kedge.C definition-added"}) {
+		t.Errorf("unexpected non-breaking changes %v", nonBreaking)
+	}
+}
+
+// Returns the changes as '<path> <kind>', nil if there are none
+func changeList(changes []Change) []string {
+	var list []string
+	for _, c := range changes {
+		list = append(list, c.Path+" "+c.Kind)
+	}
+	return list
+}
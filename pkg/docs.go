@@ -0,0 +1,290 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-openapi/spec"
+)
+
+// Formats the reference docs can be written in
+const (
+	DocsMarkdown = "markdown"
+	DocsHTML     = "html"
+)
+
+// Docs is the reference documentation of Kedge file format
+type Docs struct {
+	Title string
+	// Sections of the Kedge definitions
+	Sections []DocSection
+	// Sections of the upstream definitions reachable from Kedge ones
+	Upstream []DocSection
+}
+
+// DocSection documents a single definition
+type DocSection struct {
+	Key         string
+	Name        string
+	Anchor      string
+	Description string
+	Fields      []DocField
+}
+
+// DocField is a row in the field table of a section
+type DocField struct {
+	Name string
+	// Type like 'string' or '[]ContainerSpec', where the name of the
+	// definition the field refers to is used instead of 'Object'
+	Type string
+	// Name of the definition the field refers to, blank if there is none
+	RefName string
+	// Anchor of the section of the definition the field refers to
+	RefAnchor   string
+	Required    bool
+	Default     string
+	Description string
+}
+
+// Returns the reference docs of all Kedge definitions, upstream definitions
+// that can be reached from them get their own sections too, so every type
+// used in Kedge files can be linked to
+func BuildDocs(defs spec.Definitions, title string) (*Docs, error) {
+	roots := KedgeRoots(defs)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no Kedge definitions found")
+	}
+	reachable, err := PruneDefinitions(defs, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := sortedSchemaKeys(reachable)
+	anchors := docAnchors(keys)
+	e := NewExplainer(reachable)
+
+	d := &Docs{Title: title}
+	for _, key := range keys {
+		def := reachable[key]
+		section := DocSection{
+			Key:         key,
+			Name:        docName(key),
+			Anchor:      anchors[key],
+			Description: def.Description,
+		}
+		children, err := e.childFields(def)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			f := DocField{
+				Name:        c.Name,
+				Type:        c.Type,
+				Required:    c.Required,
+				Description: c.Description,
+			}
+			ref, err := e.refKey(c.schema)
+			if err != nil {
+				return nil, err
+			}
+			if ref != "" {
+				name := docName(ref)
+				t := strings.Replace(f.Type, "Object", name, 1)
+				// references to definitions of primitives like 'int-or-string'
+				// keep the type of the primitive, so there is nothing to link
+				if strings.Contains(t, name) {
+					f.Type = t
+					f.RefName = name
+					f.RefAnchor = anchors[ref]
+				}
+			}
+			if c.schema.Default != nil {
+				b, err := json.Marshal(c.schema.Default)
+				if err != nil {
+					return nil, err
+				}
+				f.Default = string(b)
+			}
+			section.Fields = append(section.Fields, f)
+		}
+
+		if strings.HasPrefix(key, KedgeKeyPrefix) {
+			d.Sections = append(d.Sections, section)
+		} else {
+			d.Upstream = append(d.Upstream, section)
+		}
+	}
+	return d, nil
+}
+
+// Writes the docs in the given format
+func WriteDocs(w io.Writer, d *Docs, format string) error {
+	switch format {
+	case DocsMarkdown:
+		return markdownDocsTemplate.Execute(w, d)
+	case DocsHTML:
+		return htmlDocsTemplate.Execute(w, d)
+	}
+	return fmt.Errorf("unknown docs format %q", format)
+}
+
+// Returns the short name of definition, the last part of its key
+func docName(key string) string {
+	return key[strings.LastIndex(key, ".")+1:]
+}
+
+var nonAnchorChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Returns anchors for the definition keys, they only depend on the keys so
+// links to them don't change between runs, keys that end up with the same
+// anchor get a numbered suffix in sorted order
+func docAnchors(keys []string) map[string]string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	anchors := make(map[string]string)
+	used := make(map[string]bool)
+	for _, k := range sorted {
+		base := strings.Trim(nonAnchorChars.ReplaceAllString(strings.ToLower(k), "-"), "-")
+		anchor := base
+		for i := 2; used[anchor]; i++ {
+			anchor = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[anchor] = true
+		anchors[k] = anchor
+	}
+	return anchors
+}
+
+// Makes text fit in a single markdown table cell
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Replace(s, "|", `\|`, -1)
+}
+
+// Returns the type of field for markdown, linking to the referred section
+func markdownType(f DocField) string {
+	if f.RefAnchor == "" {
+		return "`" + f.Type + "`"
+	}
+	i := strings.Index(f.Type, f.RefName)
+	if f.RefName == "" || i < 0 {
+		return fmt.Sprintf("[%s](#%s)", f.Type, f.RefAnchor)
+	}
+	prefix, suffix := f.Type[:i], f.Type[i+len(f.RefName):]
+	s := fmt.Sprintf("[%s](#%s)", f.RefName, f.RefAnchor)
+	if prefix != "" {
+		s = "`" + prefix + "`" + s
+	}
+	if suffix != "" {
+		s = s + "`" + suffix + "`"
+	}
+	return s
+}
+
+var markdownDocsTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"cell": markdownCell,
+	"type": markdownType,
+}).Parse(`# {{.Title}}
+
+## Contents
+{{range .Sections}}
+- [{{.Name}}](#{{.Anchor}}){{end}}
+{{- if .Upstream}}
+- [Upstream types](#upstream-types){{range .Upstream}}
+  - [{{.Name}}](#{{.Anchor}}){{end}}{{end}}
+{{range .Sections}}{{template "section" .}}{{end}}
+{{- if .Upstream}}
+<a name="upstream-types"></a>
+## Upstream types
+
+Types defined by Kubernetes and OpenShift that are used in Kedge files.
+{{range .Upstream}}{{template "section" .}}{{end}}{{end}}
+{{- define "section"}}
+<a name="{{.Anchor}}"></a>
+### {{.Name}}
+
+` + "`{{.Key}}`" + `
+{{if .Description}}
+{{.Description}}
+{{end}}
+{{- if .Fields}}
+| Field | Type | Required | Default | Description |
+| ----- | ---- | -------- | ------- | ----------- |
+{{range .Fields}}| ` + "`{{.Name}}`" + ` | {{type .}} | {{if .Required}}yes{{else}}no{{end}} | {{if .Default}}` + "`{{.Default}}`" + `{{end}} | {{cell .Description}} |
+{{end}}{{end}}{{end}}`))
+
+var htmlDocsTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 1000px; margin: auto; padding: 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+code { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Contents</h2>
+<ul>
+{{- range .Sections}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+{{- if .Upstream}}
+<li><a href="#upstream-types">Upstream types</a>
+<ul>
+{{- range .Upstream}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+{{range .Sections}}{{template "section" .}}{{end}}
+{{- if .Upstream}}
+<h2 id="upstream-types">Upstream types</h2>
+<p>Types defined by Kubernetes and OpenShift that are used in Kedge files.</p>
+{{range .Upstream}}{{template "section" .}}{{end}}
+{{- end}}
+</body>
+</html>
+{{define "section"}}
+<h3 id="{{.Anchor}}">{{.Name}}</h3>
+<p><code>{{.Key}}</code></p>
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+{{- if .Fields}}
+<table>
+<tr><th>Field</th><th>Type</th><th>Required</th><th>Default</th><th>Description</th></tr>
+{{- range .Fields}}
+<tr><td><code>{{.Name}}</code></td><td>{{if .RefAnchor}}<a href="#{{.RefAnchor}}">{{.Type}}</a>{{else}}<code>{{.Type}}</code>{{end}}</td><td>{{if .Required}}yes{{else}}no{{end}}</td><td>{{if .Default}}<code>{{.Default}}</code>{{end}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{end}}`))
@@ -0,0 +1,91 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDocsReferences(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {
+			"type": "object",
+			"properties": {
+				"port": {"$ref": "#/definitions/io.k8s.util.intstr.IntOrString"},
+				"time": {"$ref": "#/definitions/io.k8s.meta.v1.Time"},
+				"limits": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.resource.Quantity"}},
+				"probe": {"$ref": "#/definitions/io.k8s.api.v1.Probe"},
+				"probes": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.api.v1.Probe"}}
+			}
+		},
+		"io.k8s.util.intstr.IntOrString": {"type": "string", "format": "int-or-string"},
+		"io.k8s.meta.v1.Time": {"type": "string", "format": "date-time"},
+		"io.k8s.resource.Quantity": {"type": "string"},
+		"io.k8s.api.v1.Probe": {"type": "object", "properties": {"period": {"type": "integer"}}}
+	}`)
+
+	d, err := BuildDocs(defs, "Kedge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]DocField)
+	for _, f := range d.Sections[0].Fields {
+		fields[f.Name] = f
+	}
+
+	tests := []struct {
+		field    string
+		typ      string
+		refName  string
+		markdown string
+	}{
+		{"port", "int-or-string", "", "`int-or-string`"},
+		{"time", "string", "", "`string`"},
+		{"limits", "map[string]string", "", "`map[string]string`"},
+		{"probe", "Probe", "Probe", "[Probe](#io-k8s-api-v1-probe)"},
+		{"probes", "[]Probe", "Probe", "`[]`[Probe](#io-k8s-api-v1-probe)"},
+	}
+	for _, test := range tests {
+		f := fields[test.field]
+		if f.Type != test.typ || f.RefName != test.refName {
+			t.Errorf("%s: expected type %q referring to %q, got %q referring to %q", test.field, test.typ, test.refName, f.Type, f.RefName)
+		}
+		if got := markdownType(f); got != test.markdown {
+			t.Errorf("%s: expected markdown type %q, got %q", test.field, test.markdown, got)
+		}
+	}
+
+	for _, format := range []string{DocsMarkdown, DocsHTML} {
+		var b bytes.Buffer
+		if err := WriteDocs(&b, d, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !strings.Contains(b.String(), "int-or-string") {
+			t.Errorf("%s: type of port is missing", format)
+		}
+	}
+}
+
+func TestMarkdownTypeWithoutRefName(t *testing.T) {
+	// fields built by hand can have an anchor without the name in the type
+	f := DocField{Type: "int-or-string", RefName: "IntOrString", RefAnchor: "intorstring"}
+	if got, expected := markdownType(f), "[int-or-string](#intorstring)"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
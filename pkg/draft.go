@@ -0,0 +1,240 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Drafts of JSON Schema the definitions can be written in
+const (
+	Draft4      = "4"
+	Draft6      = "6"
+	Draft7      = "7"
+	Draft201909 = "2019-09"
+	Draft202012 = "2020-12"
+)
+
+// All the drafts of JSON Schema in the order they were published
+var Drafts = []string{Draft4, Draft6, Draft7, Draft201909, Draft202012}
+
+// URIs of the meta-schemas of drafts, written as '$schema'
+var draftSchemaURIs = map[string]string{
+	Draft4:      "http://json-schema.org/draft-04/schema#",
+	Draft6:      "http://json-schema.org/draft-06/schema#",
+	Draft7:      "http://json-schema.org/draft-07/schema#",
+	Draft201909: "https://json-schema.org/draft/2019-09/schema",
+	Draft202012: "https://json-schema.org/draft/2020-12/schema",
+}
+
+// Returns an error if the draft is not known, blank draft is fine and
+// means that definitions are written as they are in the OpenAPI schema
+func CheckDraft(draft string) error {
+	if draft == "" {
+		return nil
+	}
+	if _, ok := draftSchemaURIs[draft]; !ok {
+		return fmt.Errorf("unknown JSON Schema draft %q, must be one of: %s", draft, strings.Join(Drafts, ", "))
+	}
+	return nil
+}
+
+// Returns the keyword that definitions are kept under in the draft,
+// 'definitions' was renamed to '$defs' in 2019-09
+func DraftDefinitionsKeyword(draft string) string {
+	if draft == Draft4 || draft == Draft6 || draft == Draft7 {
+		return "definitions"
+	}
+	return "$defs"
+}
+
+// Returns the OpenAPI schema v as a JSON Schema document of the given
+// draft, with '$schema' and with the identifier id if it is not blank.
+// OpenAPI schemas are mostly draft 4, so keywords that changed after it
+// are rewritten e.g. 'exclusiveMinimum' is a number since draft 6, and
+// 'x-nullable' is turned into the 'null' type.
+func JSONSchemaDocument(v interface{}, draft, id string) (map[string]interface{}, error) {
+	if err := CheckDraft(draft); err != nil {
+		return nil, err
+	}
+	tree, err := jsonTree(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema is not a JSON object")
+	}
+	if draft == "" {
+		return doc, nil
+	}
+
+	doc = convertDraft(doc, draft)
+	doc["$schema"] = draftSchemaURIs[draft]
+	if id != "" {
+		if draft == Draft4 {
+			doc["id"] = id
+		} else {
+			doc["$id"] = id
+		}
+	}
+	return doc, nil
+}
+
+// Returns the identifier of the file published under the base URL
+func draftID(base, file string) string {
+	if base == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/" + file
+}
+
+// Rewrites the schema s and the schemas nested in it from the OpenAPI
+// dialect to the draft, s is changed in place
+func convertDraft(s map[string]interface{}, draft string) map[string]interface{} {
+	// nested schemas first, so that the ones moved around are not
+	// converted twice
+	for _, k := range []string{"not", "additionalProperties", "additionalItems"} {
+		if sub, ok := s[k].(map[string]interface{}); ok {
+			s[k] = convertDraft(sub, draft)
+		}
+	}
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		convertDraftList(s[k], draft)
+	}
+	switch items := s["items"].(type) {
+	case map[string]interface{}:
+		s["items"] = convertDraft(items, draft)
+	case []interface{}:
+		convertDraftList(items, draft)
+	}
+	for _, k := range []string{"properties", "patternProperties", "definitions", "$defs"} {
+		if m, ok := s[k].(map[string]interface{}); ok {
+			for name, sub := range m {
+				if subSchema, ok := sub.(map[string]interface{}); ok {
+					m[name] = convertDraft(subSchema, draft)
+				}
+			}
+		}
+	}
+	if deps, ok := s["dependencies"].(map[string]interface{}); ok {
+		for name, dep := range deps {
+			if sub, ok := dep.(map[string]interface{}); ok {
+				deps[name] = convertDraft(sub, draft)
+			}
+		}
+	}
+
+	if draft != Draft4 {
+		// exclusive limits are numbers of their own instead of flags
+		for _, limit := range []string{"minimum", "maximum"} {
+			exclusive := "exclusive" + strings.ToUpper(limit[:1]) + limit[1:]
+			if flag, ok := s[exclusive].(bool); ok {
+				delete(s, exclusive)
+				if v, ok := s[limit]; ok && flag {
+					s[exclusive] = v
+					delete(s, limit)
+				}
+			}
+		}
+		if id, ok := s["id"].(string); ok {
+			delete(s, "id")
+			s["$id"] = id
+		}
+	}
+
+	if draft == Draft201909 || draft == Draft202012 {
+		if defs, ok := s["definitions"]; ok {
+			delete(s, "definitions")
+			s["$defs"] = defs
+		}
+		// dependencies were split by what they depend on
+		if deps, ok := s["dependencies"].(map[string]interface{}); ok {
+			delete(s, "dependencies")
+			schemas, required := make(map[string]interface{}), make(map[string]interface{})
+			for name, dep := range deps {
+				if _, ok := dep.([]interface{}); ok {
+					required[name] = dep
+				} else {
+					schemas[name] = dep
+				}
+			}
+			if len(schemas) > 0 {
+				s["dependentSchemas"] = schemas
+			}
+			if len(required) > 0 {
+				s["dependentRequired"] = required
+			}
+		}
+	}
+	if draft == Draft202012 {
+		// arrays of items are 'prefixItems' and the rest are 'items'
+		if items, ok := s["items"].([]interface{}); ok {
+			delete(s, "items")
+			s["prefixItems"] = items
+			if additional, ok := s["additionalItems"]; ok {
+				delete(s, "additionalItems")
+				s["items"] = additional
+			}
+		}
+	}
+
+	if nullable, ok := s["x-nullable"].(bool); ok {
+		delete(s, "x-nullable")
+		if nullable {
+			s = allowNull(s)
+		}
+	}
+	return s
+}
+
+func convertDraftList(v interface{}, draft string) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	for i, item := range list {
+		if sub, ok := item.(map[string]interface{}); ok {
+			list[i] = convertDraft(sub, draft)
+		}
+	}
+}
+
+// Returns the schema that also allows null
+func allowNull(s map[string]interface{}) map[string]interface{} {
+	if enum, ok := s["enum"].([]interface{}); ok {
+		s["enum"] = append(enum, nil)
+	}
+	switch t := s["type"].(type) {
+	case string:
+		s["type"] = []interface{}{t, "null"}
+		return s
+	case []interface{}:
+		for _, v := range t {
+			if v == "null" {
+				return s
+			}
+		}
+		s["type"] = append(t, "null")
+		return s
+	}
+	// without a type, like with '$ref', only a schema next to it can add null
+	return map[string]interface{}{
+		"anyOf": []interface{}{s, map[string]interface{}{"type": "null"}},
+	}
+}
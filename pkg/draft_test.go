@@ -0,0 +1,166 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchemaDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		draft    string
+		id       string
+		schema   string
+		expected string
+	}{
+		{
+			name:     "no draft is left as it is",
+			schema:   `{"type": "integer", "minimum": 0, "exclusiveMinimum": true}`,
+			expected: `{"type": "integer", "minimum": 0, "exclusiveMinimum": true}`,
+		},
+		{
+			name:     "draft 4 keeps exclusive flags",
+			draft:    Draft4,
+			id:       "https://example.com/a.json",
+			schema:   `{"type": "integer", "minimum": 0, "exclusiveMinimum": true}`,
+			expected: `{"$schema": "http://json-schema.org/draft-04/schema#", "id": "https://example.com/a.json", "type": "integer", "minimum": 0, "exclusiveMinimum": true}`,
+		},
+		{
+			name:     "exclusive limits are numbers since draft 6",
+			draft:    Draft7,
+			id:       "https://example.com/a.json",
+			schema:   `{"type": "integer", "minimum": 0, "exclusiveMinimum": true, "maximum": 9, "exclusiveMaximum": false}`,
+			expected: `{"$schema": "http://json-schema.org/draft-07/schema#", "$id": "https://example.com/a.json", "type": "integer", "exclusiveMinimum": 0, "maximum": 9}`,
+		},
+		{
+			name:     "nullable adds the null type",
+			draft:    Draft7,
+			schema:   `{"properties": {"a": {"type": "string", "enum": ["x"], "x-nullable": true}, "b": {"$ref": "#/definitions/b", "x-nullable": true}}}`,
+			expected: `{"$schema": "http://json-schema.org/draft-07/schema#", "properties": {"a": {"type": ["string", "null"], "enum": ["x", null]}, "b": {"anyOf": [{"$ref": "#/definitions/b"}, {"type": "null"}]}}}`,
+		},
+		{
+			name:     "definitions are renamed and converted",
+			draft:    Draft201909,
+			schema:   `{"definitions": {"a": {"type": "number", "maximum": 1, "exclusiveMaximum": true}}}`,
+			expected: `{"$schema": "https://json-schema.org/draft/2019-09/schema", "$defs": {"a": {"type": "number", "exclusiveMaximum": 1}}}`,
+		},
+		{
+			name:     "dependencies are split",
+			draft:    Draft202012,
+			schema:   `{"dependencies": {"a": ["b"], "c": {"required": ["d"]}}}`,
+			expected: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "dependentRequired": {"a": ["b"]}, "dependentSchemas": {"c": {"required": ["d"]}}}`,
+		},
+		{
+			name:     "tuples are prefix items",
+			draft:    Draft202012,
+			schema:   `{"items": [{"type": "string"}, {"type": "integer", "x-nullable": true}], "additionalItems": {"type": "boolean"}}`,
+			expected: `{"$schema": "https://json-schema.org/draft/2020-12/schema", "prefixItems": [{"type": "string"}, {"type": ["integer", "null"]}], "items": {"type": "boolean"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		var schema interface{}
+		if err := json.Unmarshal([]byte(test.schema), &schema); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		doc, err := JSONSchemaDocument(schema, test.draft, test.id)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		assertJSONEqual(t, test.name, test.expected, doc)
+	}
+}
+
+func TestJSONSchemaDocumentBundle(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.A": {
+			"type": "object",
+			"properties": {
+				"b": {"$ref": "#/definitions/io.kedge.B"},
+				"n": {"type": "integer", "minimum": 0, "exclusiveMinimum": true}
+			}
+		},
+		"io.kedge.B": {"type": "string", "x-nullable": true}
+	}`)
+
+	tests := []struct {
+		draft    string
+		keyword  string
+		expected string
+	}{
+		{Draft7, "definitions", `{
+			"io.kedge.A": {
+				"type": "object",
+				"properties": {
+					"b": {"$ref": "#/definitions/io.kedge.B"},
+					"n": {"type": "integer", "exclusiveMinimum": 0}
+				}
+			},
+			"io.kedge.B": {"type": ["string", "null"]}
+		}`},
+		{Draft201909, "$defs", `{
+			"io.kedge.A": {
+				"type": "object",
+				"properties": {
+					"b": {"$ref": "#/$defs/io.kedge.B"},
+					"n": {"type": "integer", "exclusiveMinimum": 0}
+				}
+			},
+			"io.kedge.B": {"type": ["string", "null"]}
+		}`},
+		{Draft202012, "$defs", `{
+			"io.kedge.A": {
+				"type": "object",
+				"properties": {
+					"b": {"$ref": "#/$defs/io.kedge.B"},
+					"n": {"type": "integer", "exclusiveMinimum": 0}
+				}
+			},
+			"io.kedge.B": {"type": ["string", "null"]}
+		}`},
+	}
+	for _, test := range tests {
+		doc, err := JSONSchemaDocument(BundleDefinitions(defs, test.draft), test.draft, "")
+		if err != nil {
+			t.Fatalf("%s: %v", test.draft, err)
+		}
+		assertJSONEqual(t, test.draft, test.expected, doc[test.keyword])
+	}
+}
+
+// Fails the test if actual is not the same as the expected JSON
+func assertJSONEqual(t *testing.T, name, expected string, actual interface{}) {
+	t.Helper()
+	var e, a interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	b, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if err := json.Unmarshal(b, &a); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if !reflect.DeepEqual(e, a) {
+		t.Errorf("%s: expected %s, got %s", name, expected, b)
+	}
+}
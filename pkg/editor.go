@@ -0,0 +1,128 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Files editors use the Kedge schema for when not told otherwise
+var DefaultFileMatch = []string{"*.kedge.yaml", "*.kedge.yml"}
+
+// Schema of the catalog used by SchemaStore and the editors that read it
+const schemaCatalogSchema = "https://json.schemastore.org/schema-catalog.json"
+
+// SchemaCatalog is a catalog of schemas in the format of SchemaStore, see
+// https://www.schemastore.org/api/json/catalog.json
+type SchemaCatalog struct {
+	Schema  string         `json:"$schema"`
+	Version float64        `json:"version"`
+	Schemas []CatalogEntry `json:"schemas"`
+}
+
+// CatalogEntry is a single schema in the SchemaCatalog
+type CatalogEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Globs of the files the schema is used for e.g. '*.kedge.yaml'
+	FileMatch []string `json:"fileMatch"`
+	// URL of the latest schema
+	URL string `json:"url"`
+	// URLs of the schema of every version by the version label
+	Versions map[string]string `json:"versions,omitempty"`
+}
+
+// Returns the catalog with the given entries, the entries are checked to
+// have what SchemaStore needs
+func NewSchemaCatalog(entries ...CatalogEntry) (*SchemaCatalog, error) {
+	for _, e := range entries {
+		if e.Name == "" {
+			return nil, fmt.Errorf("catalog entry has no name")
+		}
+		urls := []string{e.URL}
+		for _, u := range e.Versions {
+			urls = append(urls, u)
+		}
+		for _, u := range urls {
+			if !isHTTPURL(u) {
+				return nil, fmt.Errorf("catalog entry %q: %q is not an http or https URL", e.Name, u)
+			}
+		}
+	}
+	return &SchemaCatalog{
+		Schema:  schemaCatalogSchema,
+		Version: 1,
+		Schemas: entries,
+	}, nil
+}
+
+// Returns the settings that make yaml-language-server, e.g. the YAML
+// extension of VS Code, use the schema for the files matching the globs.
+// Schema is the URL or the path of the schema file.
+func YAMLSchemasSettings(schema string, fileMatch []string) map[string]interface{} {
+	return map[string]interface{}{
+		"yaml.schemas": map[string][]string{
+			schema: fileMatch,
+		},
+	}
+}
+
+// Returns the file the root definition is written to by --out-dir,
+// which is the schema of whole Kedge files
+func AppSchemaFile(defs spec.Definitions, format string) (string, error) {
+	if _, ok := defs[AppKey]; !ok {
+		return "", &DefinitionNotFoundError{Key: AppKey}
+	}
+	return SplitFileNames(sortedSchemaKeys(defs), format)[AppKey], nil
+}
+
+// Returns where the schema of whole Kedge files is found after it is
+// written by --out-dir, its URL if the files are published under a
+// base URL or else its path
+func AppSchemaLocation(defs spec.Definitions, out OutputConfig) (string, error) {
+	if out.OutDir == "" {
+		return "", fmt.Errorf("definition directory is needed, the schema of Kedge files is written there")
+	}
+	file, err := AppSchemaFile(defs, out.Format)
+	if err != nil {
+		return "", err
+	}
+	if out.IDBase != "" {
+		return draftID(out.IDBase, file), nil
+	}
+	return filepath.Join(out.OutDir, file), nil
+}
+
+// Parses version of the schema given in the form '<version>=<url>'
+// e.g. 'v1.7=https://example.com/v1.7/app.json'
+func ParseSchemaVersion(s string) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("version %q is not of the form <version>=<url>", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaCatalog(t *testing.T) {
+	catalog, err := NewSchemaCatalog(CatalogEntry{
+		Name:        "Kedge",
+		Description: "Kedge application definition",
+		FileMatch:   DefaultFileMatch,
+		URL:         "https://example.com/schema/v1.8/app.json",
+		Versions: map[string]string{
+			"v1.8": "https://example.com/schema/v1.8/app.json",
+			"v1.7": "https://example.com/schema/v1.7/app.json",
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not create catalog: %v", err)
+	}
+	var b bytes.Buffer
+	if err := WriteJSON(&b, catalog); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "catalog.json", b.Bytes())
+
+	tests := []struct {
+		name  string
+		entry CatalogEntry
+	}{
+		{"no name", CatalogEntry{URL: "https://example.com/app.json"}},
+		{"path", CatalogEntry{Name: "Kedge", URL: "schema/app.json"}},
+		{"version path", CatalogEntry{Name: "Kedge", URL: "https://example.com/app.json", Versions: map[string]string{"v1.7": "file:///app.json"}}},
+	}
+	for _, test := range tests {
+		if _, err := NewSchemaCatalog(test.entry); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+func TestYAMLSchemasSettings(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {"type": "object"},
+		"io.kedge.ContainerSpec": {"type": "object"}
+	}`)
+	location, err := AppSchemaLocation(defs, OutputConfig{OutDir: "schema", Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join("schema", "app.json"); location != expected {
+		t.Errorf("expected location %q, got %q", expected, location)
+	}
+	location, err = AppSchemaLocation(defs, OutputConfig{OutDir: "schema", Format: FormatJSON, IDBase: "https://example.com/schema/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := WriteJSON(&b, YAMLSchemasSettings(location, DefaultFileMatch)); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "settings.json", b.Bytes())
+
+	if _, err := AppSchemaLocation(defs, OutputConfig{Format: FormatJSON}); err == nil {
+		t.Errorf("expected error without definition directory")
+	}
+}
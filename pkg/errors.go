@@ -0,0 +1,102 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// SourceError is returned when an input of generation could not be read
+// or is not what it is supposed to be, e.g. the upstream schema is not JSON
+type SourceError struct {
+	// What the source is for, one of 'kedge', 'kubernetes' or 'openshift'
+	Kind string
+	// Name of the source, for files it is their location
+	Name string
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s: %q: %v", e.Kind, e.Name, e.Err)
+}
+
+// Returns the underlying error, so that errors.Cause() can find it
+func (e *SourceError) Cause() error {
+	return e.Err
+}
+
+// Returns the underlying error, so that errors.Is() and errors.As() of the
+// standard library can find it
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when Kedge spec source code can't be parsed or has
+// something that can't be converted to a definition, it points to the
+// position in the source where it was found
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// Returns ParseError for the given position in the file set
+func newParseError(fset *token.FileSet, pos token.Pos, err error) *ParseError {
+	p := fset.Position(pos)
+	return &ParseError{File: p.Filename, Line: p.Line, Column: p.Column, Message: err.Error()}
+}
+
+// Converts errors returned by the go parser, which already know their
+// positions, to ParseError
+func toParseError(name string, err error) *ParseError {
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		p := list[0].Pos
+		return &ParseError{File: p.Filename, Line: p.Line, Column: p.Column, Message: list[0].Msg}
+	}
+	return &ParseError{File: name, Message: err.Error()}
+}
+
+// DriftError is returned when output is only checked and the files that
+// were generated earlier are not the same as what would be generated now
+type DriftError struct {
+	Files []string
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("generated output is not up to date: %s", strings.Join(e.Files, ", "))
+}
+
+// DefinitionNotFoundError is returned when a definition that is needed,
+// like a root definition or the target of a reference, is not defined
+type DefinitionNotFoundError struct {
+	Key string
+}
+
+func (e *DefinitionNotFoundError) Error() string {
+	return fmt.Sprintf("definition %q not found", e.Key)
+}
@@ -0,0 +1,481 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// Width that comments of generated examples are wrapped at
+const exampleCommentWidth = 80
+
+// Returns an example Kedge file in YAML for the root, which is 'app' for the
+// root definition, a controller name like 'job' for Kedge files of that
+// controller or a definition key. Only the required fields are set, to
+// values taken from defaults, enums and limits of the schema. If full is set,
+// optional fields are listed too as commented out lines, and every field has
+// its description in a comment above it. Objects without required fields
+// then have their first optional field set, so that any of the commented out
+// lines can be uncommented. The example is validated against
+// the definitions before it is returned.
+func GenerateExample(defs spec.Definitions, root string, full bool) ([]byte, error) {
+	key, branch, err := exampleRoot(defs, root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &exampleWriter{defs: defs, full: full, stack: make(map[string]bool)}
+	if err := w.writeRoot(defs[key], branch); err != nil {
+		return nil, err
+	}
+	out := w.b.Bytes()
+
+	errs, err := NewValidator(defs, false).ValidateReader(key, root, bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		// e.g. strings with patterns that the placeholders don't match, a
+		// default or an example in the schema fixes it
+		return nil, fmt.Errorf("example of %q is not valid, %s: %s", root, errs[0].Path, errs[0].Message)
+	}
+	return out, nil
+}
+
+// Returns the key of the definition the example is of and the title of the
+// 'oneOf' branch of it to use
+func exampleRoot(defs spec.Definitions, root string) (string, string, error) {
+	if root == AppPathName {
+		root = AppKey
+	}
+	for _, c := range Controllers {
+		if root != c.Name {
+			continue
+		}
+		// the branch of the root definition has the 'controller' field
+		if _, ok := defs[AppKey]; ok {
+			return AppKey, c.Name, nil
+		}
+		root = c.Key
+	}
+	if _, ok := defs[root]; !ok {
+		return "", "", &DefinitionNotFoundError{Key: root}
+	}
+	return root, "", nil
+}
+
+// exampleWriter writes example values of schemas in YAML
+type exampleWriter struct {
+	defs spec.Definitions
+	full bool
+	b    bytes.Buffer
+	// keys of the definitions whose values are being written, required
+	// fields referring back to them are left empty
+	stack map[string]bool
+}
+
+// Writes the example of the root definition, branch is the title of the
+// 'oneOf' or 'anyOf' branch to use
+func (w *exampleWriter) writeRoot(s spec.Schema, branch string) error {
+	if w.full && s.Description != "" {
+		w.writeComment(s.Description, "")
+		w.b.WriteString("\n")
+	}
+	s, err := w.deref(s)
+	if err != nil {
+		return err
+	}
+	if !exampleIsObject(s) {
+		value, err := w.scalar("", s)
+		if err != nil {
+			return err
+		}
+		w.b.WriteString(value + "\n")
+		return nil
+	}
+	if !w.hasFields(s) {
+		w.b.WriteString("{}\n")
+		return nil
+	}
+	_, err = w.writeObject(s, branch, "")
+	return err
+}
+
+// Writes the fields of the object indented by indent and returns the number
+// of fields that were set
+func (w *exampleWriter) writeObject(s spec.Schema, branch, indent string) (int, error) {
+	fields := make(map[string]spec.Schema)
+	required := make(map[string]bool)
+	if err := w.fields(s, branch, fields, required); err != nil {
+		return 0, err
+	}
+
+	// required fields come first, so that they are not lost between the
+	// optional ones
+	var names, optional []string
+	for _, name := range sortedSchemaKeys(fields) {
+		if required[name] {
+			names = append(names, name)
+		} else if w.full {
+			optional = append(optional, name)
+		}
+	}
+	if len(names) == 0 && len(optional) > 0 {
+		// with only commented out fields the object would be null
+		names, optional = optional[:1], optional[1:]
+		required = map[string]bool{names[0]: true}
+	}
+
+	var set int
+	for _, name := range append(names, optional...) {
+		f := fields[name]
+		if w.full {
+			desc := f.Description
+			if desc == "" {
+				if d, err := w.deref(f); err == nil {
+					desc = d.Description
+				}
+			}
+			w.writeComment(desc, indent)
+		}
+		if !required[name] {
+			if err := w.writeCommented(name, f, indent); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := w.writeField(name, f, indent); err != nil {
+			return 0, err
+		}
+		set++
+	}
+	return set, nil
+}
+
+// Writes the required field with the example of its value
+func (w *exampleWriter) writeField(name string, s spec.Schema, indent string) error {
+	key := RefKey(s.Ref)
+	if key != "" && w.stack[key] {
+		// a required field of itself, it can't be written in full
+		fmt.Fprintf(&w.b, "%s%s: {}\n", indent, exampleYAML(name))
+		return nil
+	}
+	w.stack[key] = true
+	defer delete(w.stack, key)
+
+	d, err := w.deref(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case exampleIsArray(d) && d.Items != nil && d.Items.Schema != nil:
+		fmt.Fprintf(&w.b, "%s%s:\n", indent, exampleYAML(name))
+		return w.writeItems(name, d, indent)
+	case exampleIsObject(d) && w.hasFields(d):
+		fmt.Fprintf(&w.b, "%s%s:\n", indent, exampleYAML(name))
+		_, err := w.writeObject(d, "", indent+"  ")
+		return err
+	}
+	value, err := w.inline(name, s)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&w.b, "%s%s: %s\n", indent, exampleYAML(name), value)
+	return nil
+}
+
+// Writes the optional field like a required one without its optional fields,
+// as commented out lines
+func (w *exampleWriter) writeCommented(name string, s spec.Schema, indent string) error {
+	c := &exampleWriter{defs: w.defs, stack: w.stack}
+	if err := c.writeField(name, s, indent); err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(c.b.String(), "\n") {
+		if line != "" {
+			w.b.WriteString(indent + "# " + strings.TrimPrefix(line, indent))
+		}
+	}
+	return nil
+}
+
+// Writes the items of the array, as many as it needs to have and at least
+// one so that the example shows what they look like
+func (w *exampleWriter) writeItems(name string, s spec.Schema, indent string) error {
+	count := 1
+	if s.MinItems != nil && *s.MinItems > 1 {
+		count = int(*s.MinItems)
+	}
+	item := *s.Items.Schema
+	d, err := w.deref(item)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		if !exampleIsObject(d) || !w.hasFields(d) {
+			value, err := w.inline(name, item)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&w.b, "%s- %s\n", indent, value)
+			continue
+		}
+
+		// the item is written like a field of an object, then its first line
+		// is made to start the item
+		b := w.b
+		w.b = bytes.Buffer{}
+		_, err := w.writeObject(d, "", indent+"  ")
+		lines := w.b.String()
+		w.b = b
+		if err != nil {
+			return err
+		}
+		w.b.WriteString(indent + "- " + strings.TrimPrefix(lines, indent+"  "))
+	}
+	return nil
+}
+
+// Returns the value of the schema as written on a single line, objects and
+// arrays are empty
+func (w *exampleWriter) inline(name string, s spec.Schema) (string, error) {
+	d, err := w.deref(s)
+	if err != nil {
+		return "", err
+	}
+	if d.Default == nil && d.Example == nil && len(d.Enum) == 0 {
+		switch {
+		case exampleIsArray(d):
+			return "[]", nil
+		case exampleIsObject(d):
+			return "{}", nil
+		}
+	}
+	return w.scalar(name, d)
+}
+
+// Returns the example of a value of the schema in YAML, which is its
+// default, its example or the first of its enum values if it has any
+func (w *exampleWriter) scalar(name string, s spec.Schema) (string, error) {
+	switch {
+	case s.Default != nil:
+		return exampleYAML(s.Default), nil
+	case s.Example != nil:
+		return exampleYAML(s.Example), nil
+	case len(s.Enum) > 0:
+		return exampleYAML(s.Enum[0]), nil
+	case s.Format == "int-or-string":
+		return exampleYAML(exampleNumber(s, true)), nil
+	}
+
+	var t string
+	if len(s.Type) > 0 {
+		t = s.Type[0]
+	}
+	switch t {
+	case "string":
+		return exampleYAML(exampleString(name, s)), nil
+	case "integer":
+		return exampleYAML(exampleNumber(s, true)), nil
+	case "number":
+		return exampleYAML(exampleNumber(s, false)), nil
+	case "boolean":
+		return "false", nil
+	case "null":
+		return "null", nil
+	case "array":
+		return "[]", nil
+	}
+	return "{}", nil
+}
+
+// Collects the fields of the object and which of them are required into
+// fields and required, with the 'oneOf' or 'anyOf' branch titled branch, or
+// the first one
+func (w *exampleWriter) fields(s spec.Schema, branch string, fields map[string]spec.Schema, required map[string]bool) error {
+	pick := func(branches []spec.Schema) spec.Schema {
+		for _, b := range branches {
+			if branch != "" && b.Title == branch {
+				return b
+			}
+		}
+		return branches[0]
+	}
+	return objectFields(w.defs, s, pick, fields, required)
+}
+
+// Collects the fields of the object and which of them are required into
+// fields and required. Fields of 'allOf' are added and of 'oneOf' and
+// 'anyOf' only the branch returned by pick. Branches narrow the enums of
+// fields that are already defined, like 'controller' of the root definition.
+func objectFields(defs spec.Definitions, s spec.Schema, pick func([]spec.Schema) spec.Schema, fields map[string]spec.Schema, required map[string]bool) error {
+	s, err := derefSchema(defs, s)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedSchemaKeys(s.Properties) {
+		f := s.Properties[name]
+		existing, ok := fields[name]
+		if !ok {
+			fields[name] = f
+			continue
+		}
+		if len(f.Enum) > 0 {
+			existing.Enum = f.Enum
+			fields[name] = existing
+		}
+	}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	for _, sub := range s.AllOf {
+		if err := objectFields(defs, sub, pick, fields, required); err != nil {
+			return err
+		}
+	}
+	for _, branches := range [][]spec.Schema{s.OneOf, s.AnyOf} {
+		if len(branches) == 0 {
+			continue
+		}
+		if err := objectFields(defs, pick(branches), pick, fields, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if the object is written with its fields on their own lines,
+// which it is when it has required fields, or any fields when the optional
+// ones are listed too
+func (w *exampleWriter) hasFields(s spec.Schema) bool {
+	fields := make(map[string]spec.Schema)
+	required := make(map[string]bool)
+	if err := w.fields(s, "", fields, required); err != nil {
+		return false
+	}
+	if w.full {
+		return len(fields) > 0
+	}
+	return len(required) > 0
+}
+
+// Follows the references until a schema that is not a reference is found
+func (w *exampleWriter) deref(s spec.Schema) (spec.Schema, error) {
+	return derefSchema(w.defs, s)
+}
+
+// Follows the references until a schema that is not a reference is found
+func derefSchema(defs spec.Definitions, s spec.Schema) (spec.Schema, error) {
+	for i := 0; s.Ref.String() != ""; i++ {
+		if i > len(defs) {
+			return s, fmt.Errorf("reference %q refers to itself", s.Ref.String())
+		}
+		key := RefKey(s.Ref)
+		def, ok := defs[key]
+		if !ok {
+			return s, &DefinitionNotFoundError{Key: key}
+		}
+		s = def
+	}
+	return s, nil
+}
+
+// Writes the text as YAML comment lines
+func (w *exampleWriter) writeComment(text, indent string) {
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n") {
+		w.b.WriteString(wrapText(paragraph, indent+"# ", exampleCommentWidth))
+	}
+}
+
+func exampleIsObject(s spec.Schema) bool {
+	if len(s.Type) > 0 {
+		return s.Type.Contains("object")
+	}
+	return len(s.Properties) > 0 || len(s.AllOf) > 0 || len(s.OneOf) > 0 || len(s.AnyOf) > 0
+}
+
+func exampleIsArray(s spec.Schema) bool {
+	return s.Type.Contains("array")
+}
+
+// Returns the field name as placeholder of strings, padded or cut to fit
+// the length limits
+func exampleString(name string, s spec.Schema) string {
+	switch s.Format {
+	case "date-time":
+		return "2017-01-01T00:00:00Z"
+	case "date":
+		return "2017-01-01"
+	}
+	value := name
+	if value == "" {
+		value = "value"
+	}
+	if s.MinLength != nil {
+		for int64(len(value)) < *s.MinLength {
+			value += "x"
+		}
+	}
+	if s.MaxLength != nil && int64(len(value)) > *s.MaxLength {
+		value = value[:*s.MaxLength]
+	}
+	return value
+}
+
+// Returns 1 moved into the limits of the schema, integers are rounded to the
+// nearest allowed integer
+func exampleNumber(s spec.Schema, integer bool) interface{} {
+	v := 1.0
+	if s.Minimum != nil && (v < *s.Minimum || (s.ExclusiveMinimum && v == *s.Minimum)) {
+		v = *s.Minimum
+		if s.ExclusiveMinimum {
+			v++
+		}
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		v = math.Ceil(v / *s.MultipleOf) * *s.MultipleOf
+	}
+	if s.Maximum != nil && (v > *s.Maximum || (s.ExclusiveMaximum && v == *s.Maximum)) {
+		v = *s.Maximum
+		if s.ExclusiveMaximum {
+			v--
+		}
+	}
+	if integer {
+		return int64(math.Ceil(v))
+	}
+	return v
+}
+
+// Returns the value as YAML on a single line
+func exampleYAML(v interface{}) string {
+	out, err := yaml.Marshal(v)
+	if err == nil && bytes.Count(out, []byte("\n")) == 1 {
+		return strings.TrimSuffix(string(out), "\n")
+	}
+	// multi-line strings and collections, JSON is YAML too
+	out, _ = json.Marshal(v)
+	return string(out)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateExample(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.ContainerSpec": {
+			"type": "object",
+			"required": ["image", "ports", "resources"],
+			"properties": {
+				"image": {"type": "string", "description": "Docker image name"},
+				"ports": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.Port"}},
+				"resources": {"$ref": "#/definitions/io.k8s.Resources"},
+				"metadata": {"$ref": "#/definitions/io.k8s.Resources"}
+			}
+		},
+		"io.k8s.Port": {
+			"type": "object",
+			"properties": {
+				"name": {"type": "string", "description": "Name of the port"},
+				"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+			}
+		},
+		"io.k8s.Resources": {
+			"type": "object",
+			"description": "Compute resources",
+			"properties": {"limits": {"type": "object", "additionalProperties": {"type": "string"}}}
+		}
+	}`)
+
+	tests := []struct {
+		root     string
+		full     bool
+		expected string
+	}{
+		{"io.kedge.ContainerSpec", false, `image: image
+ports:
+- {}
+resources: {}
+`},
+		// objects without required fields have their first optional field
+		// set, so that the others can be uncommented
+		{"io.kedge.ContainerSpec", true, `# Docker image name
+image: image
+ports:
+- # Name of the port
+  name: name
+  # port: 1
+# Compute resources
+resources:
+  limits: {}
+# Compute resources
+# metadata: {}
+`},
+		{"io.k8s.Port", false, "{}\n"},
+		{"io.k8s.Port", true, `# Name of the port
+name: name
+# port: 1
+`},
+	}
+	for _, test := range tests {
+		out, err := GenerateExample(defs, test.root, test.full)
+		if err != nil {
+			t.Errorf("%s (full %v): %v", test.root, test.full, err)
+			continue
+		}
+		if string(out) != test.expected {
+			t.Errorf("%s (full %v): expected\n%s\ngot\n%s", test.root, test.full, test.expected, out)
+		}
+	}
+}
+
+func TestGenerateExampleUncommented(t *testing.T) {
+	// without descriptions every comment is an optional field
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string"},
+				"containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.Container"}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"spec": {"$ref": "#/definitions/io.k8s.Spec"}
+			}
+		},
+		"io.k8s.Container": {
+			"type": "object",
+			"properties": {
+				"image": {"type": "string"},
+				"ports": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.Port"}}
+			}
+		},
+		"io.k8s.Port": {
+			"type": "object",
+			"required": ["port"],
+			"properties": {
+				"name": {"type": "string"},
+				"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+			}
+		},
+		"io.k8s.Spec": {
+			"type": "object",
+			"required": ["containers"],
+			"properties": {
+				"containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.Container"}},
+				"replicas": {"type": "integer", "minimum": 1}
+			}
+		}
+	}`)
+
+	for _, root := range []string{"io.kedge.App", "io.k8s.Container", "io.k8s.Port", "io.k8s.Spec"} {
+		out, err := GenerateExample(defs, root, true)
+		if err != nil {
+			t.Errorf("%s: %v", root, err)
+			continue
+		}
+		lines := bytes.Split(out, []byte("\n"))
+		for i, line := range lines {
+			lines[i] = bytes.Replace(line, []byte("# "), nil, 1)
+		}
+		uncommented := bytes.Join(lines, []byte("\n"))
+		if bytes.Equal(uncommented, out) {
+			t.Errorf("%s: expected optional fields to uncomment in\n%s", root, out)
+		}
+		errs, err := NewValidator(defs, false).ValidateReader(root, "example.yaml", bytes.NewReader(uncommented))
+		if err != nil {
+			t.Errorf("%s: %v", root, err)
+			continue
+		}
+		if len(errs) > 0 {
+			t.Errorf("%s: uncommented example\n%s\nis invalid: %v", root, uncommented, errs)
+		}
+	}
+}
+
+func TestGenerateExampleInvalid(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.A": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"name": {"type": "string", "pattern": "^[0-9]+$"}}
+		}
+	}`)
+	if _, err := GenerateExample(defs, "io.kedge.A", false); err == nil {
+		t.Error("expected the example to be rejected, the placeholder does not match the pattern")
+	}
+}
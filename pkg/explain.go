@@ -0,0 +1,401 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Name used in paths to refer to the root definition
+const AppPathName = "app"
+
+// Explanation describes the field found at a path given to Explain
+type Explanation struct {
+	Path string
+	// Key of the definition the field refers to, blank if it is defined inline
+	Definition  string
+	Type        string
+	Description string
+	Required    bool
+	// Child fields sorted by name, for arrays these are fields of the items
+	Fields []ExplainedField
+
+	schema spec.Schema
+}
+
+// ExplainedField is a child field of an explained field
+type ExplainedField struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+
+	schema spec.Schema
+}
+
+// Explainer finds fields in definitions using dotted paths the same way
+// 'kubectl explain' does
+type Explainer struct {
+	defs spec.Definitions
+}
+
+// Returns explainer for the given definitions
+func NewExplainer(defs spec.Definitions) *Explainer {
+	return &Explainer{defs: defs}
+}
+
+// Returns explanation of the field at path. The path starts with 'app' for
+// the root definition, a controller name like 'job' or a definition key,
+// followed by field names separated by dots e.g. 'app.containers.health'.
+// Arrays are stepped into, so '[]' after a field name is optional. References
+// are followed and fields of all 'allOf', 'oneOf' and 'anyOf' branches are
+// merged together.
+func (e *Explainer) Explain(path string) (*Explanation, error) {
+	key, rest, err := e.splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	def, ok := e.defs[key]
+	if !ok {
+		return nil, &DefinitionNotFoundError{Key: key}
+	}
+
+	x := &Explanation{schema: def, Definition: key, Description: def.Description}
+	// path of the field being looked into, as the user has written it
+	current := strings.TrimSuffix(strings.TrimSuffix(path, strings.Join(rest, ".")), ".")
+	for i := range rest {
+		name := strings.TrimSuffix(rest[i], "[]")
+		fields, required, err := e.fields(x.schema)
+		if err != nil {
+			return nil, err
+		}
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("field %q does not exist in %q", name, current)
+		}
+		current += "." + rest[i]
+
+		x = &Explanation{
+			schema:      field,
+			Description: field.Description,
+			Required:    required[name],
+		}
+		if x.Definition, err = e.refKey(field); err != nil {
+			return nil, err
+		}
+		if x.Description == "" && x.Definition != "" {
+			x.Description = e.defs[x.Definition].Description
+		}
+	}
+	x.Path = path
+
+	if x.Type, err = e.typeName(x.schema); err != nil {
+		return nil, err
+	}
+	if x.Fields, err = e.childFields(x.schema); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Splits the path into the definition key it starts with and field names
+func (e *Explainer) splitPath(path string) (string, []string, error) {
+	parts := strings.Split(path, ".")
+	if parts[0] == AppPathName {
+		return AppKey, parts[1:], nil
+	}
+	for _, c := range Controllers {
+		if parts[0] == c.Name {
+			return c.Key, parts[1:], nil
+		}
+	}
+	// definition keys have dots in them, so the longest matching one is used
+	for i := len(parts); i > 0; i-- {
+		key := strings.Join(parts[:i], ".")
+		if _, ok := e.defs[key]; ok {
+			return key, parts[i:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("path %q does not start with %q, a controller name or a definition key", path, AppPathName)
+}
+
+// Follows the references until a schema that is not a reference is found
+func (e *Explainer) deref(s spec.Schema) (spec.Schema, error) {
+	for i := 0; s.Ref.String() != ""; i++ {
+		if i > len(e.defs) {
+			return s, fmt.Errorf("reference %q refers to itself", s.Ref.String())
+		}
+		key := RefKey(s.Ref)
+		def, ok := e.defs[key]
+		if !ok {
+			return s, &DefinitionNotFoundError{Key: key}
+		}
+		s = def
+	}
+	return s, nil
+}
+
+// Returns the schema of elements if the schema is of an array, else the
+// schema itself, references are followed in both cases
+func (e *Explainer) elem(s spec.Schema) (spec.Schema, error) {
+	s, err := e.deref(s)
+	for err == nil && s.Items != nil && s.Items.Schema != nil {
+		s, err = e.deref(*s.Items.Schema)
+	}
+	return s, err
+}
+
+// Returns key of the definition the schema or its array elements refer to
+func (e *Explainer) refKey(s spec.Schema) (string, error) {
+	for s.Ref.String() == "" && s.Items != nil && s.Items.Schema != nil {
+		s = *s.Items.Schema
+	}
+	key := RefKey(s.Ref)
+	if key == "" {
+		return "", nil
+	}
+	if _, ok := e.defs[key]; !ok {
+		return "", &DefinitionNotFoundError{Key: key}
+	}
+	return key, nil
+}
+
+// Returns the fields of the object that schema or its array elements
+// describe and which of them are required. Fields of all the branches of
+// 'allOf', 'oneOf' and 'anyOf' are merged, fields from 'oneOf' and 'anyOf'
+// are only required when they are required in every branch.
+func (e *Explainer) fields(s spec.Schema) (map[string]spec.Schema, map[string]bool, error) {
+	s, err := e.elem(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := make(map[string]spec.Schema)
+	required := stringSet(s.Required)
+	for k, v := range s.Properties {
+		fields[k] = v
+	}
+	merge := func(sub spec.Schema) (map[string]bool, error) {
+		f, r, err := e.fields(sub)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range f {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+		return r, nil
+	}
+
+	for _, sub := range s.AllOf {
+		r, err := merge(sub)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k := range r {
+			required[k] = true
+		}
+	}
+	for _, branches := range [][]spec.Schema{s.OneOf, s.AnyOf} {
+		var common map[string]bool
+		for _, sub := range branches {
+			r, err := merge(sub)
+			if err != nil {
+				return nil, nil, err
+			}
+			if common == nil {
+				common = r
+				continue
+			}
+			for k := range common {
+				if !r[k] {
+					delete(common, k)
+				}
+			}
+		}
+		for k := range common {
+			required[k] = true
+		}
+	}
+	return fields, required, nil
+}
+
+// Returns the child fields of schema sorted by name
+func (e *Explainer) childFields(s spec.Schema) ([]ExplainedField, error) {
+	fields, required, err := e.fields(s)
+	if err != nil {
+		return nil, err
+	}
+	var children []ExplainedField
+	for _, name := range sortedSchemaKeys(fields) {
+		f := fields[name]
+		t, err := e.typeName(f)
+		if err != nil {
+			return nil, err
+		}
+		desc := f.Description
+		if desc == "" {
+			if d, err := e.deref(f); err == nil {
+				desc = d.Description
+			}
+		}
+		children = append(children, ExplainedField{
+			Name:        name,
+			Type:        t,
+			Description: desc,
+			Required:    required[name],
+			schema:      f,
+		})
+	}
+	return children, nil
+}
+
+// Returns short human readable type of the schema like 'string', 'Object',
+// '[]Object' or 'map[string]string'
+func (e *Explainer) typeName(s spec.Schema) (string, error) {
+	s, err := e.deref(s)
+	if err != nil {
+		return "", err
+	}
+	if s.Format == "int-or-string" {
+		return "int-or-string", nil
+	}
+
+	var t string
+	if len(s.Type) > 0 {
+		t = s.Type[0]
+	}
+	switch t {
+	case "array":
+		if s.Items == nil || s.Items.Schema == nil {
+			return "[]", nil
+		}
+		item, err := e.typeName(*s.Items.Schema)
+		return "[]" + item, err
+	case "object", "":
+		if len(s.Properties) == 0 && s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			value, err := e.typeName(*s.AdditionalProperties.Schema)
+			return "map[string]" + value, err
+		}
+		if t == "" && len(s.Properties) == 0 && len(s.AllOf) == 0 && len(s.OneOf) == 0 && len(s.AnyOf) == 0 {
+			return "any", nil
+		}
+		return "Object", nil
+	}
+	return t, nil
+}
+
+// Writes the explanation in the form 'kubectl explain' does, if recursive
+// then all the fields nested under it are written as a tree instead of only
+// the child fields with their descriptions
+func (e *Explainer) WriteText(w io.Writer, x *Explanation, recursive bool) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PATH:       %s\n", x.Path)
+	fmt.Fprintf(&b, "TYPE:       <%s>\n", x.Type)
+	if x.Definition != "" {
+		fmt.Fprintf(&b, "DEFINITION: %s\n", x.Definition)
+	}
+	fmt.Fprintf(&b, "REQUIRED:   %v\n", x.Required)
+	if x.Description != "" {
+		fmt.Fprintf(&b, "\nDESCRIPTION:\n%s", wrapText(x.Description, "     ", 80))
+	}
+
+	if len(x.Fields) > 0 {
+		b.WriteString("\nFIELDS:\n")
+		if recursive {
+			stack := make(map[string]bool)
+			if x.Definition != "" {
+				stack[x.Definition] = true
+			}
+			if err := e.writeTree(&b, x.Fields, "   ", stack); err != nil {
+				return err
+			}
+		} else {
+			for _, f := range x.Fields {
+				fmt.Fprintf(&b, "   %s\t<%s>%s\n", f.Name, f.Type, requiredMark(f.Required))
+				if f.Description != "" {
+					b.WriteString(wrapText(f.Description, "     ", 80))
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes the fields and the fields nested under them, definitions which are
+// already being written higher up in the tree are not expanded again
+func (e *Explainer) writeTree(b *bytes.Buffer, fields []ExplainedField, indent string, stack map[string]bool) error {
+	for _, f := range fields {
+		fmt.Fprintf(b, "%s%s\t<%s>%s\n", indent, f.Name, f.Type, requiredMark(f.Required))
+
+		key, err := e.refKey(f.schema)
+		if err != nil {
+			return err
+		}
+		if stack[key] {
+			continue
+		}
+		children, err := e.childFields(f.schema)
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			stack[key] = true
+		}
+		if err := e.writeTree(b, children, indent+"   ", stack); err != nil {
+			return err
+		}
+		delete(stack, key)
+	}
+	return nil
+}
+
+func requiredMark(required bool) string {
+	if required {
+		return " -required-"
+	}
+	return ""
+}
+
+// Wraps the text into lines no longer than width where possible, each line
+// starts with indent
+func wrapText(text, indent string, width int) string {
+	var b bytes.Buffer
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if line != indent && len(line)+1+len(word) > width {
+			b.WriteString(line + "\n")
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += word
+	}
+	if line != indent {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+// Names of the upstream files that are fetched
+const (
+	UpstreamKubernetes = "kubernetes"
+	UpstreamOpenShift  = "openshift"
+	UpstreamKedge      = "kedge"
+)
+
+// How long downloading a single file can take, unless the Fetcher is given
+// its own client
+const DefaultFetchTimeout = 5 * time.Minute
+
+// Upstream is a single file that is fetched from the URL made out of
+// '<BaseURL>/<Version>/<Path>'
+type Upstream struct {
+	// Name used to identify the file in the lock file
+	Name    string
+	BaseURL string
+	// Version is a git tag, branch or commit in the upstream repositories
+	Version string
+	Path    string
+}
+
+// Returns the URL of the upstream file
+func (u Upstream) URL() string {
+	return strings.TrimSuffix(u.BaseURL, "/") + "/" + path.Join(u.Version, u.Path)
+}
+
+// Returns the default upstream files, these are the same that are
+// downloaded by 'scripts/entrypoint.sh'
+func DefaultUpstreams(k8sRelease string) []Upstream {
+	return []Upstream{
+		{
+			Name:    UpstreamKubernetes,
+			BaseURL: "https://raw.githubusercontent.com/kubernetes/kubernetes",
+			Version: k8sRelease,
+			Path:    "api/openapi-spec/swagger.json",
+		},
+		{
+			Name:    UpstreamOpenShift,
+			BaseURL: "https://raw.githubusercontent.com/openshift/origin",
+			Version: "1252cce6daeca1b6cc0fd90b1bde5dcdc9a0853b",
+			Path:    "api/swagger-spec/oapi-v1.json",
+		},
+		{
+			Name:    UpstreamKedge,
+			BaseURL: "https://raw.githubusercontent.com/kedgeproject/kedge",
+			Version: "master",
+			Path:    "pkg/spec/types.go",
+		},
+	}
+}
+
+// LockFile records exactly what was fetched, so that later runs can use
+// the cached files and check that they were not changed
+type LockFile struct {
+	Files []LockedFile `json:"files"`
+}
+
+// LockedFile is a single fetched file recorded in the LockFile
+type LockedFile struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Version string `json:"version"`
+	// Hex encoded SHA-256 checksum of the file content
+	SHA256 string `json:"sha256"`
+	// Location of the file relative to the cache directory
+	Path string `json:"path"`
+}
+
+// Returns the entry with the given name, nil if there is none
+func (l *LockFile) Get(name string) *LockedFile {
+	for i := range l.Files {
+		if l.Files[i].Name == name {
+			return &l.Files[i]
+		}
+	}
+	return nil
+}
+
+// Adds the entry replacing the existing one with the same name
+func (l *LockFile) Set(f LockedFile) {
+	if e := l.Get(f.Name); e != nil {
+		*e = f
+		return
+	}
+	l.Files = append(l.Files, f)
+}
+
+// Returns location of the cached file with the given name after checking
+// that its content still matches the checksum recorded in the lock file
+func (l *LockFile) Resolve(name, cacheDir string) (string, error) {
+	f := l.Get(name)
+	if f == nil {
+		return "", fmt.Errorf("%q is not found in the lock file, run 'schemagen fetch'", name)
+	}
+	filename := filepath.Join(cacheDir, filepath.FromSlash(f.Path))
+	if err := VerifyChecksum(filename, f.SHA256); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// Reads the lock file, if the file does not exist an empty lock is returned
+func ReadLockFile(filename string) (*LockFile, error) {
+	lock := &LockFile{}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read lock file %q", filename)
+	}
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, errors.Wrapf(err, "could not parse lock file %q", filename)
+	}
+	return lock, nil
+}
+
+// Fetcher downloads upstream files into a local cache and records them
+// in the lock file
+type Fetcher struct {
+	CacheDir string
+	LockFile string
+	// Client used to download, a client with DefaultFetchTimeout if not
+	// given
+	Client *http.Client
+	// Update downloads the files again even if they are cached
+	Update bool
+	// Offline never downloads and fails if any file is not cached
+	Offline bool
+}
+
+// Makes sure all the upstream files are in the cache and recorded in the
+// lock file. Files already recorded with the same URL are only checked
+// against their checksum, so that no network is needed, unless Update is set.
+func (f *Fetcher) Fetch(upstreams []Upstream) (*LockFile, error) {
+	lock, err := ReadLockFile(f.LockFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range upstreams {
+		url := u.URL()
+		rel := path.Join(u.Name, u.Version, path.Base(u.Path))
+		filename := filepath.Join(f.CacheDir, filepath.FromSlash(rel))
+
+		locked := lock.Get(u.Name)
+		if locked != nil && locked.URL == url && !f.Update {
+			// already locked, the content has to match the lock file either
+			// from the cache or fetched again if it is not cached anymore
+			if _, err := os.Stat(filepath.Join(f.CacheDir, filepath.FromSlash(locked.Path))); err == nil {
+				if _, err := lock.Resolve(u.Name, f.CacheDir); err != nil {
+					return nil, err
+				}
+				log.Infof("%s: using cached %q", u.Name, locked.Path)
+				continue
+			}
+		}
+		if f.Offline {
+			return nil, fmt.Errorf("%s: %q is not cached and fetching is disabled", u.Name, url)
+		}
+
+		sum, err := f.download(url, filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", u.Name)
+		}
+		if locked != nil && locked.URL == url && !f.Update && locked.SHA256 != sum {
+			os.Remove(filename)
+			return nil, fmt.Errorf("%s: checksum of %q does not match the lock file: expected %s, got %s", u.Name, url, locked.SHA256, sum)
+		}
+		log.Infof("%s: fetched %q", u.Name, url)
+		lock.Set(LockedFile{Name: u.Name, URL: url, Version: u.Version, SHA256: sum, Path: rel})
+	}
+
+	if err := WriteJSONFile(f.LockFile, lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Downloads the URL into filename and returns the checksum of its content
+func (f *Fetcher) download(url, filename string) (string, error) {
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not fetch %q", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not fetch %q: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", errors.Wrapf(err, "could not create cache directory")
+	}
+	// write to a temporary file first so that failed downloads don't
+	// leave broken files in the cache
+	tmp := filename + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create file %q", tmp)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", errors.Wrapf(err, "could not download %q", url)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		return "", errors.Wrapf(err, "could not write file %q", filename)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Returns hex encoded SHA-256 checksum of the file's content
+func FileChecksum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Checks that the content of the file matches the given checksum
+func VerifyChecksum(filename, sum string) error {
+	actual, err := FileChecksum(filename)
+	if err != nil {
+		return errors.Wrapf(err, "could not read cached file %q", filename)
+	}
+	if actual != sum {
+		return fmt.Errorf("checksum of cached file %q does not match the lock file: expected %s, got %s", filename, sum, actual)
+	}
+	return nil
+}
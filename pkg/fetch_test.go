@@ -0,0 +1,207 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// upstreamServer serves the files of its map and counts the requests
+type upstreamServer struct {
+	*httptest.Server
+	files    map[string]string
+	requests int
+}
+
+func newUpstreamServer(files map[string]string) *upstreamServer {
+	s := &upstreamServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		content, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	return s
+}
+
+// Returns a Fetcher using a new temporary directory, which is removed by the
+// returned function
+func testFetcher(t *testing.T) (*Fetcher, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "schemagen-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{
+		CacheDir: filepath.Join(dir, "cache"),
+		LockFile: filepath.Join(dir, "schemagen.lock"),
+	}
+	return f, func() { os.RemoveAll(dir) }
+}
+
+func TestFetch(t *testing.T) {
+	server := newUpstreamServer(map[string]string{
+		"/v1.7.0/api/swagger.json": `{"swagger": "2.0"}`,
+	})
+	defer server.Close()
+	f, cleanup := testFetcher(t)
+	defer cleanup()
+	upstreams := []Upstream{{Name: UpstreamKubernetes, BaseURL: server.URL, Version: "v1.7.0", Path: "api/swagger.json"}}
+
+	lock, err := f.Fetch(upstreams)
+	if err != nil {
+		t.Fatalf("could not fetch: %v", err)
+	}
+	locked := lock.Get(UpstreamKubernetes)
+	if locked == nil {
+		t.Fatalf("%q is not in the lock file", UpstreamKubernetes)
+	}
+	sum, err := FileChecksum(filepath.Join(f.CacheDir, filepath.FromSlash(locked.Path)))
+	if err != nil {
+		t.Fatalf("fetched file is not cached: %v", err)
+	}
+	expected := LockedFile{
+		Name:    UpstreamKubernetes,
+		URL:     server.URL + "/v1.7.0/api/swagger.json",
+		Version: "v1.7.0",
+		SHA256:  sum,
+		Path:    "kubernetes/v1.7.0/swagger.json",
+	}
+	if *locked != expected {
+		t.Errorf("expected lock entry %+v, got %+v", expected, *locked)
+	}
+
+	// the lock is written and read back
+	lock, err = ReadLockFile(f.LockFile)
+	if err != nil {
+		t.Fatalf("could not read lock file: %v", err)
+	}
+	filename, err := lock.Resolve(UpstreamKubernetes, f.CacheDir)
+	if err != nil {
+		t.Fatalf("could not resolve %q: %v", UpstreamKubernetes, err)
+	}
+	if content, _ := ioutil.ReadFile(filename); string(content) != `{"swagger": "2.0"}` {
+		t.Errorf("unexpected content of the resolved file: %s", content)
+	}
+	if _, err := lock.Resolve(UpstreamOpenShift, f.CacheDir); err == nil {
+		t.Errorf("expected error resolving %q that was not fetched", UpstreamOpenShift)
+	}
+
+	// cached files are used without the network, even when offline
+	server.requests = 0
+	f.Offline = true
+	if _, err := f.Fetch(upstreams); err != nil {
+		t.Fatalf("could not fetch cached files: %v", err)
+	}
+	if server.requests != 0 {
+		t.Errorf("expected cached files to be used, got %d requests", server.requests)
+	}
+}
+
+func TestFetchChecksumMismatch(t *testing.T) {
+	server := newUpstreamServer(map[string]string{
+		"/master/types.go": "package spec",
+	})
+	defer server.Close()
+	upstreams := []Upstream{{Name: UpstreamKedge, BaseURL: server.URL, Version: "master", Path: "types.go"}}
+
+	tests := []struct {
+		name string
+		// changes made after the first fetch
+		change func(f *Fetcher)
+	}{
+		{
+			"changed upstream",
+			func(f *Fetcher) {
+				os.RemoveAll(f.CacheDir)
+				server.files["/master/types.go"] = "package changed"
+			},
+		},
+		{
+			"changed cached file",
+			func(f *Fetcher) {
+				filename := filepath.Join(f.CacheDir, "kedge", "master", "types.go")
+				if err := ioutil.WriteFile(filename, []byte("package changed"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		server.files["/master/types.go"] = "package spec"
+		f, cleanup := testFetcher(t)
+		if _, err := f.Fetch(upstreams); err != nil {
+			cleanup()
+			t.Fatalf("%s: could not fetch: %v", test.name, err)
+		}
+		test.change(f)
+
+		_, err := f.Fetch(upstreams)
+		if err == nil || !strings.Contains(err.Error(), "does not match the lock file") {
+			t.Errorf("%s: expected checksum mismatch error, got %v", test.name, err)
+		}
+		lock, _ := ReadLockFile(f.LockFile)
+		if _, err := lock.Resolve(UpstreamKedge, f.CacheDir); err == nil {
+			t.Errorf("%s: expected resolving the changed file to fail", test.name)
+		}
+
+		// updating accepts the new content
+		f.Update = true
+		if _, err := f.Fetch(upstreams); err != nil {
+			t.Errorf("%s: could not update: %v", test.name, err)
+		}
+		cleanup()
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	server := newUpstreamServer(map[string]string{})
+	defer server.Close()
+	upstreams := []Upstream{{Name: UpstreamKedge, BaseURL: server.URL, Version: "master", Path: "types.go"}}
+
+	tests := []struct {
+		name    string
+		offline bool
+		err     string
+	}{
+		{"not found", false, "404 Not Found"},
+		{"offline", true, "is not cached and fetching is disabled"},
+	}
+
+	for _, test := range tests {
+		f, cleanup := testFetcher(t)
+		f.Offline = test.offline
+		_, err := f.Fetch(upstreams)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+		}
+		if _, statErr := os.Stat(f.LockFile); !os.IsNotExist(statErr) {
+			t.Errorf("%s: expected no lock file to be written", test.name)
+		}
+		cleanup()
+	}
+}
@@ -0,0 +1,543 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"regexp/syntax"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-openapi/spec"
+)
+
+// Defaults of the limits of Fuzzer
+const (
+	DefaultFuzzRecursion    = 2
+	DefaultFuzzOptionalRate = 0.5
+	DefaultFuzzMaxItems     = 3
+	DefaultFuzzMaxLength    = 16
+)
+
+// How many times a value is generated again when it does not fit all the
+// limits of its schema, e.g. a string of a pattern that is too long
+const fuzzAttempts = 100
+
+// Range of numbers without limits in the schema
+const fuzzNumberRange = 1000
+
+// Fuzzer generates random values that are valid against the definitions,
+// for property based tests of tools that read Kedge files. The same seed
+// gives the same values for the same definitions.
+type Fuzzer struct {
+	// How many times a definition can be nested in itself, optional fields
+	// that would nest it deeper are left out
+	MaxRecursion int
+	// Probability of an optional field being set
+	OptionalRate float64
+	// Most items of arrays and fields of maps, on top of the ones that the
+	// schema needs to have, when the schema has no limit
+	MaxItems int
+	// Longest string when the schema has no limit
+	MaxLength int
+
+	defs spec.Definitions
+	rand *rand.Rand
+	// how many times each definition is nested in the value being generated
+	stack    map[string]int
+	patterns map[string]*regexp.Regexp
+}
+
+// Returns fuzzer of the definitions whose random values come from seed
+func NewFuzzer(defs spec.Definitions, seed int64) *Fuzzer {
+	return &Fuzzer{
+		MaxRecursion: DefaultFuzzRecursion,
+		OptionalRate: DefaultFuzzOptionalRate,
+		MaxItems:     DefaultFuzzMaxItems,
+		MaxLength:    DefaultFuzzMaxLength,
+		defs:         defs,
+		rand:         rand.New(rand.NewSource(seed)),
+		stack:        make(map[string]int),
+		patterns:     make(map[string]*regexp.Regexp),
+	}
+}
+
+// Returns a random value of the root, which is 'app' for the root
+// definition, a controller name like 'job' for Kedge files of that controller
+// or a definition key, the same as for GenerateExample. Objects are
+// map[string]interface{}, arrays are []interface{} and numbers are int64 or
+// float64, so the value can be written in any format.
+func (f *Fuzzer) Instance(root string) (interface{}, error) {
+	key, branch, err := exampleRoot(f.defs, root)
+	if err != nil {
+		return nil, err
+	}
+	s := refSchema(key)
+	if branch != "" {
+		// the branch of the controller is the only one of the root
+		def, err := derefSchema(f.defs, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range def.OneOf {
+			if b.Title == branch {
+				def.OneOf = []spec.Schema{b}
+			}
+		}
+		s = def
+	}
+	return f.value(s)
+}
+
+// Returns a random value of the schema
+func (f *Fuzzer) value(s spec.Schema) (interface{}, error) {
+	if key := RefKey(s.Ref); key != "" {
+		def, ok := f.defs[key]
+		if !ok {
+			return nil, &DefinitionNotFoundError{Key: key}
+		}
+		if f.stack[key] > f.MaxRecursion {
+			return nil, fmt.Errorf("definition %q needs to be nested in itself more than %d times", key, f.MaxRecursion)
+		}
+		f.stack[key]++
+		defer func() { f.stack[key]-- }()
+		return f.value(def)
+	}
+	if s.Ref.String() != "" {
+		return nil, fmt.Errorf("unresolved reference %q", s.Ref.String())
+	}
+
+	if len(s.Enum) > 0 {
+		return s.Enum[f.rand.Intn(len(s.Enum))], nil
+	}
+	if s.Format == "int-or-string" && f.rand.Intn(2) == 0 {
+		return f.integer(s)
+	}
+
+	t := f.pickType(s)
+	switch t {
+	case "object":
+		return f.object(s)
+	case "array":
+		return f.array(s)
+	case "string":
+		return f.string(s)
+	case "integer":
+		return f.integer(s)
+	case "number":
+		return f.number(s)
+	case "boolean":
+		return f.rand.Intn(2) == 0, nil
+	}
+	return nil, nil
+}
+
+// Returns one of the types of the schema, schemas without type can be
+// anything their keywords allow
+func (f *Fuzzer) pickType(s spec.Schema) string {
+	if len(s.Type) > 0 {
+		return s.Type[f.rand.Intn(len(s.Type))]
+	}
+	if len(s.Properties) > 0 || len(s.AllOf) > 0 || len(s.OneOf) > 0 || len(s.AnyOf) > 0 || s.AdditionalProperties != nil {
+		return "object"
+	}
+	if s.Items != nil {
+		return "array"
+	}
+	types := []string{"string", "integer", "boolean"}
+	return types[f.rand.Intn(len(types))]
+}
+
+// Returns a random object with all the required fields and some of the
+// optional ones, maps get random keys
+func (f *Fuzzer) object(s spec.Schema) (interface{}, error) {
+	fields := make(map[string]spec.Schema)
+	required := make(map[string]bool)
+	pick := func(branches []spec.Schema) spec.Schema {
+		return branches[f.rand.Intn(len(branches))]
+	}
+	if err := objectFields(f.defs, s, pick, fields, required); err != nil {
+		return nil, err
+	}
+
+	var min, max int64 = 0, math.MaxInt64
+	if s.MinProperties != nil {
+		min = *s.MinProperties
+	}
+	if s.MaxProperties != nil {
+		max = *s.MaxProperties
+	}
+
+	obj := make(map[string]interface{})
+	var optional []string
+	for _, name := range sortedSchemaKeys(fields) {
+		if !required[name] {
+			if !f.tooDeep(fields[name]) {
+				optional = append(optional, name)
+			}
+			continue
+		}
+		v, err := f.value(fields[name])
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = v
+	}
+	for _, name := range optional {
+		if int64(len(obj)) >= max {
+			break
+		}
+		// fields that are needed to have enough of them are always set
+		left := min - int64(len(obj))
+		if left <= 0 && f.rand.Float64() >= f.OptionalRate {
+			continue
+		}
+		v, err := f.value(fields[name])
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = v
+	}
+
+	// maps, objects with properties only get the fields they define
+	extra := s.AdditionalProperties
+	if len(fields) > 0 || extra == nil || extra.Schema == nil {
+		return obj, nil
+	}
+	count := f.count(min, max, f.tooDeep(*extra.Schema))
+	for attempt := 0; int64(len(obj)) < count && attempt < fuzzAttempts; attempt++ {
+		key := f.text(1+f.rand.Intn(f.MaxLength), fuzzKeyChars)
+		if _, ok := obj[key]; ok {
+			continue
+		}
+		v, err := f.value(*extra.Schema)
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = v
+	}
+	return obj, nil
+}
+
+// Returns a random array of items of the schema
+func (f *Fuzzer) array(s spec.Schema) (interface{}, error) {
+	arr := []interface{}{}
+	if s.Items == nil {
+		return arr, nil
+	}
+	// tuples
+	if s.Items.Schema == nil {
+		for _, item := range s.Items.Schemas {
+			v, err := f.value(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	}
+
+	var min, max int64 = 0, math.MaxInt64
+	if s.MinItems != nil {
+		min = *s.MinItems
+	}
+	if s.MaxItems != nil {
+		max = *s.MaxItems
+	}
+	count := f.count(min, max, f.tooDeep(*s.Items.Schema))
+	for attempt := 0; int64(len(arr)) < count; attempt++ {
+		v, err := f.value(*s.Items.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if s.UniqueItems && fuzzContains(arr, v) {
+			if attempt >= fuzzAttempts {
+				return nil, fmt.Errorf("could not generate %d unique items", count)
+			}
+			continue
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+// Returns a random number of items between the limits, only as many as
+// needed if nesting them would be too deep
+func (f *Fuzzer) count(min, max int64, tooDeep bool) int64 {
+	if tooDeep {
+		return min
+	}
+	hi := min + int64(f.MaxItems)
+	if max < hi {
+		hi = max
+	}
+	if hi <= min {
+		return min
+	}
+	return min + f.rand.Int63n(hi-min+1)
+}
+
+// Returns a random string of the pattern, format and length of the schema
+func (f *Fuzzer) string(s spec.Schema) (interface{}, error) {
+	switch s.Format {
+	case "date-time":
+		return f.time().Format(time.RFC3339), nil
+	case "date":
+		return f.time().Format("2006-01-02"), nil
+	}
+
+	var min, max int64 = 0, int64(f.MaxLength)
+	if s.MinLength != nil {
+		min = *s.MinLength
+		if max < min {
+			max = min + int64(f.MaxLength)
+		}
+	}
+	if s.MaxLength != nil {
+		max = *s.MaxLength
+	}
+	if min > max {
+		return nil, fmt.Errorf("string can't be at least %d and at most %d characters long", min, max)
+	}
+	if s.Pattern == "" {
+		return f.text(int(min+f.rand.Int63n(max-min+1)), fuzzTextChars), nil
+	}
+
+	re, err := f.compile(s.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := syntax.Parse(s.Pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	parsed = parsed.Simplify()
+	for attempt := 0; attempt < fuzzAttempts; attempt++ {
+		var b bytes.Buffer
+		f.writeRegexp(&b, parsed)
+		// the pattern can match anywhere, so text is added around too short
+		// matches when the pattern allows it
+		str := b.String()
+		if n := int64(utf8.RuneCountInString(str)); n < min {
+			str += f.text(int(min-n), fuzzTextChars)
+		}
+		n := int64(utf8.RuneCountInString(str))
+		if n >= min && n <= max && re.MatchString(str) {
+			return str, nil
+		}
+	}
+	return nil, fmt.Errorf("could not generate string of pattern %q between %d and %d characters long", s.Pattern, min, max)
+}
+
+// Writes a random string that the regular expression matches
+func (f *Fuzzer) writeRegexp(b *bytes.Buffer, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && f.rand.Intn(2) == 0 {
+				r = unicode.SimpleFold(r)
+			}
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(f.classRune(re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		b.WriteRune(rune(fuzzTextChars[f.rand.Intn(len(fuzzTextChars))]))
+	case syntax.OpCapture:
+		f.writeRegexp(b, re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		min, max := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			min, max = 0, -1
+		case syntax.OpPlus:
+			min, max = 1, -1
+		case syntax.OpQuest:
+			min, max = 0, 1
+		}
+		if max < 0 {
+			max = min + f.MaxItems
+		}
+		for i := min + f.rand.Intn(max-min+1); i > 0; i-- {
+			f.writeRegexp(b, re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			f.writeRegexp(b, sub)
+		}
+	case syntax.OpAlternate:
+		f.writeRegexp(b, re.Sub[f.rand.Intn(len(re.Sub))])
+	}
+	// anchors, word boundaries and empty matches take no text, patterns
+	// that match nothing are found when the result is checked
+}
+
+// Returns a random rune of the character class, given as pairs of the first
+// and last runes of ranges. Printable ASCII is preferred, since negated
+// classes cover all of unicode.
+func (f *Fuzzer) classRune(ranges []rune) rune {
+	if len(ranges) == 0 {
+		return 0
+	}
+	for attempt := 0; attempt < fuzzAttempts; attempt++ {
+		r := rune(fuzzTextChars[f.rand.Intn(len(fuzzTextChars))])
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if r >= ranges[i] && r <= ranges[i+1] {
+				return r
+			}
+		}
+	}
+	i := 2 * f.rand.Intn(len(ranges)/2)
+	lo, hi := ranges[i], ranges[i+1]
+	for attempt := 0; attempt < fuzzAttempts; attempt++ {
+		r := lo + rune(f.rand.Int63n(int64(hi-lo)+1))
+		if utf8.ValidRune(r) && unicode.IsPrint(r) {
+			return r
+		}
+	}
+	return lo
+}
+
+// Returns a random integer within the limits of the schema
+func (f *Fuzzer) integer(s spec.Schema) (interface{}, error) {
+	lo, hi := f.limits(s)
+	lo, hi = math.Ceil(lo), math.Floor(hi)
+	step := 1.0
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		step = *s.MultipleOf
+	}
+	first, last := math.Ceil(lo/step), math.Floor(hi/step)
+	for attempt := 0; attempt < fuzzAttempts && first <= last; attempt++ {
+		v := (first + float64(f.rand.Int63n(int64(last-first)+1))) * step
+		if v == math.Trunc(v) && fuzzWithin(s, v) {
+			return int64(v), nil
+		}
+	}
+	return nil, fmt.Errorf("could not generate integer between %v and %v", lo, hi)
+}
+
+// Returns a random number within the limits of the schema
+func (f *Fuzzer) number(s spec.Schema) (interface{}, error) {
+	lo, hi := f.limits(s)
+	for attempt := 0; attempt < fuzzAttempts && lo <= hi; attempt++ {
+		v := lo + f.rand.Float64()*(hi-lo)
+		if s.MultipleOf != nil && *s.MultipleOf > 0 {
+			v = math.Round(v / *s.MultipleOf) * *s.MultipleOf
+			if q := v / *s.MultipleOf; q != math.Trunc(q) {
+				continue
+			}
+		}
+		if fuzzWithin(s, v) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("could not generate number between %v and %v", lo, hi)
+}
+
+// Returns the range numbers of the schema are generated from, limits the
+// schema does not have are fuzzNumberRange away from the others
+func (f *Fuzzer) limits(s spec.Schema) (float64, float64) {
+	switch {
+	case s.Minimum != nil && s.Maximum != nil:
+		return *s.Minimum, *s.Maximum
+	case s.Minimum != nil:
+		return *s.Minimum, *s.Minimum + fuzzNumberRange
+	case s.Maximum != nil:
+		return *s.Maximum - fuzzNumberRange, *s.Maximum
+	}
+	return -fuzzNumberRange, fuzzNumberRange
+}
+
+// Returns true if the schema refers to a definition that is already nested
+// as deep as it can be, directly or through items and values of maps
+func (f *Fuzzer) tooDeep(s spec.Schema) bool {
+	for {
+		if key := RefKey(s.Ref); key != "" {
+			return f.stack[key] > f.MaxRecursion
+		}
+		switch {
+		case s.Items != nil && s.Items.Schema != nil:
+			s = *s.Items.Schema
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			s = *s.AdditionalProperties.Schema
+		default:
+			return false
+		}
+	}
+}
+
+func (f *Fuzzer) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := f.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.patterns[pattern] = re
+	return re, nil
+}
+
+// Returns random text of n characters from chars
+func (f *Fuzzer) text(n int, chars string) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[f.rand.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// Returns a random time in UTC, in whole seconds since that is all RFC 3339
+// keeps
+func (f *Fuzzer) time() time.Time {
+	return time.Unix(f.rand.Int63n(math.MaxInt32), 0).UTC()
+}
+
+// Characters of random strings and keys of maps
+const (
+	fuzzTextChars = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+	fuzzKeyChars  = "abcdefghijklmnopqrstuvwxyz0123456789-._"
+)
+
+// Returns true if the number is within the limits of the schema
+func fuzzWithin(s spec.Schema, v float64) bool {
+	if s.Minimum != nil && (v < *s.Minimum || s.ExclusiveMinimum && v == *s.Minimum) {
+		return false
+	}
+	if s.Maximum != nil && (v > *s.Maximum || s.ExclusiveMaximum && v == *s.Maximum) {
+		return false
+	}
+	return true
+}
+
+// Returns true if the array already has a value that is the same as v
+func fuzzContains(arr []interface{}, v interface{}) bool {
+	b, _ := json.Marshal(v)
+	for _, item := range arr {
+		other, _ := json.Marshal(item)
+		if bytes.Equal(b, other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+// How many values are generated for every root
+const testFuzzCount = 50
+
+// Returns the values the fuzzer with the seed generates for the root, as
+// YAML
+func testFuzzInstances(t *testing.T, defs spec.Definitions, root string, seed int64) [][]byte {
+	t.Helper()
+	f := NewFuzzer(defs, seed)
+	var instances [][]byte
+	for i := 0; i < testFuzzCount; i++ {
+		v, err := f.Instance(root)
+		if err != nil {
+			t.Fatalf("%s: could not generate value: %v", root, err)
+		}
+		content, err := Marshal(v, FormatYAML)
+		if err != nil {
+			t.Fatalf("%s: %v", root, err)
+		}
+		instances = append(instances, content)
+	}
+	return instances
+}
+
+func TestFuzzer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-fuzz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	api, err := GenerateOpenAPI(testConfig(t, dir))
+	if err != nil {
+		t.Fatalf("could not generate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		defs spec.Definitions
+		root string
+	}{
+		{"limits", testDefinitions(t, testValidateDefinitions), "io.kedge.App"},
+		{"app", api.Definitions, "app"},
+		{"controller", api.Definitions, "job"},
+		{"definition", api.Definitions, "io.kedge.ContainerSpec"},
+	}
+	for _, test := range tests {
+		const seed = 42
+		instances := testFuzzInstances(t, test.defs, test.root, seed)
+		again := testFuzzInstances(t, test.defs, test.root, seed)
+		key, _, err := exampleRoot(test.defs, test.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := NewValidator(test.defs, true)
+		for i, content := range instances {
+			if !bytes.Equal(content, again[i]) {
+				t.Errorf("%s: value %d differs for the same seed:\n%s\n%s", test.name, i, content, again[i])
+			}
+			errs, err := v.ValidateReader(key, "", bytes.NewReader(content))
+			if err != nil {
+				t.Fatalf("%s: value %d: %v\n%s", test.name, i, err, content)
+			}
+			if len(errs) > 0 {
+				t.Errorf("%s: value %d is not valid: %v\n%s", test.name, i, errs, content)
+			}
+		}
+
+		other := testFuzzInstances(t, test.defs, test.root, seed+1)
+		same := true
+		for i := range instances {
+			same = same && bytes.Equal(instances[i], other[i])
+		}
+		if same {
+			t.Errorf("%s: expected other seed to give other values", test.name)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Returned when the generator is not given any Kedge spec source
+	ErrNoKedgeSources = errors.New("no Kedge sources given")
+	// Returned when the generator is not given the Kubernetes schema
+	ErrNoKubernetesSchema = errors.New("no Kubernetes schema given")
+)
+
+// Source is an input of the generator, either a file on disk or content
+// that is already in memory
+type Source struct {
+	// Name of the source, for files it is their location, for content in
+	// memory it is only used to point to the source in errors
+	Name string
+	// Content of the source, if nil then the file Name is read
+	Content []byte
+}
+
+// Returns the source that reads the given file, for Kedge spec it can also
+// be a package directory
+func FileSource(filename string) Source {
+	return Source{Name: filename}
+}
+
+// Reads everything from the reader and returns the source which has it
+func ReaderSource(name string, r io.Reader) (Source, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Source{}, &SourceError{Kind: "source", Name: name, Err: err}
+	}
+	return Source{Name: name, Content: content}, nil
+}
+
+// Returns the content of the source, reading the file if it is not in memory
+func (s Source) Read() ([]byte, error) {
+	if s.Content != nil {
+		return s.Content, nil
+	}
+	return ioutil.ReadFile(s.Name)
+}
+
+// Returns true if the source is not given at all
+func (s Source) IsZero() bool {
+	return s.Name == "" && s.Content == nil
+}
+
+// GeneratorOptions has everything that decides what the Generator generates
+type GeneratorOptions struct {
+	// Go source code of Kedge spec, all the sources are parsed as one package
+	Kedge []Source
+	// OpenAPI schema of Kubernetes whose definitions are injected into
+	// Kedge definitions
+	Kubernetes Source
+	// OpenAPI schema of OpenShift, it is optional
+	OpenShift Source
+	// Aliases maps a definition key used in Kedge spec comments to the key
+	// that is actually defined upstream
+	Aliases map[string]string
+	// Required fields overrides applied on top of DefaultRequiredOverrides
+	Required map[string]RequiredOverride
+	// Only keep definitions reachable from roots
+	Prune bool
+	// Definition keys to start pruning from, defaults to Kedge definitions
+	Roots []string
+	// Diagnostics is called for every problem that does not stop generation,
+	// if not given they are logged as warnings
+	Diagnostics func(Diagnostic)
+	// Cache upstream schemas are parsed through, they are parsed every time
+	// if not given
+	Cache *SchemaCache
+}
+
+// Generator generates the OpenAPI schema for Kedge, it never exits the
+// process, problems are returned as errors or given to Diagnostics
+type Generator struct {
+	opts GeneratorOptions
+}
+
+// Returns the generator for the given options after checking them
+func NewGenerator(opts GeneratorOptions) (*Generator, error) {
+	if len(opts.Kedge) == 0 {
+		return nil, ErrNoKedgeSources
+	}
+	if opts.Kubernetes.IsZero() {
+		return nil, ErrNoKubernetesSchema
+	}
+	return &Generator{opts: opts}, nil
+}
+
+// Returns the options generator was created with
+func (g *Generator) Options() GeneratorOptions {
+	return g.opts
+}
+
+// Generates the OpenAPI schema for Kedge by injecting the definitions from
+// Kubernetes and OpenShift OpenAPI schemas into the Kedge definitions
+func (g *Generator) Generate() (*spec.Swagger, error) {
+	upstream, err := g.ParseUpstream()
+	if err != nil {
+		return nil, err
+	}
+	return g.GenerateFrom(upstream)
+}
+
+// Generates the schema and writes it as indented JSON to w
+func (g *Generator) Write(w io.Writer) error {
+	api, err := g.Generate()
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, api)
+}
+
+// Parses the Kubernetes and OpenShift schemas and returns them merged
+// into one, it can be given to GenerateFrom any number of times
+func (g *Generator) ParseUpstream() (*spec.Swagger, error) {
+	api, err := parseUpstream(UpstreamKubernetes, g.opts.Kubernetes, g.opts.Cache)
+	if err != nil {
+		return nil, err
+	}
+	if !g.opts.OpenShift.IsZero() {
+		osApi, err := parseUpstream(UpstreamOpenShift, g.opts.OpenShift, g.opts.Cache)
+		if err != nil {
+			return nil, err
+		}
+		MergeDefinitions(api, osApi)
+	}
+	return api, nil
+}
+
+// Generates the schema using already parsed upstream schema, which is left
+// as it is so that it can be used again
+func (g *Generator) GenerateFrom(upstream *spec.Swagger) (*spec.Swagger, error) {
+	defs, mapping, err := ParseKedgeSources(g.opts.Kedge)
+	if err != nil {
+		return nil, err
+	}
+
+	api := *upstream
+	api.Definitions = make(spec.Definitions, len(upstream.Definitions)+len(defs))
+	for k, v := range upstream.Definitions {
+		api.Definitions[k] = v
+	}
+
+	ApplyAliases(defs, mapping, g.opts.Aliases)
+	g.checkInjections(api.Definitions, mapping)
+	defs = InjectKedgeSpec(api.Definitions, defs, mapping)
+	ApplyRequiredOverrides(defs, DefaultRequiredOverrides)
+	ApplyRequiredOverrides(defs, g.opts.Required)
+
+	// add the root definition that works for files of any controller
+	CreateAppDefinition(defs, g.report)
+
+	// add defs to openapi
+	for k, v := range defs {
+		api.Definitions[k] = v
+	}
+
+	if g.opts.Prune || len(g.opts.Roots) > 0 {
+		if err := PruneDocument(&api, g.opts.Roots, g.report); err != nil {
+			return nil, err
+		}
+	}
+	return &api, nil
+}
+
+// Reports the injections whose sources are not found upstream, since
+// nothing is injected from them
+func (g *Generator) checkInjections(upstream spec.Definitions, mapping []Injection) {
+	for _, m := range mapping {
+		var message string
+		if m.Source == "" {
+			message = fmt.Sprintf("embedded type of %q has no 'k8s:' comment, nothing is injected from it", m.Target)
+		} else if _, ok := upstream[m.Source]; !ok {
+			message = fmt.Sprintf("%q is not defined upstream, nothing is injected from it into %q", m.Source, m.Target)
+		} else {
+			continue
+		}
+		g.report(Diagnostic{
+			Rule:     RuleInjection,
+			Severity: SeverityWarning,
+			Message:  message,
+			File:     m.File,
+			Line:     m.Line,
+			Column:   m.Column,
+		})
+	}
+}
+
+func (g *Generator) report(d Diagnostic) {
+	if g.opts.Diagnostics != nil {
+		g.opts.Diagnostics(d)
+		return
+	}
+	log.Warnln(d)
+}
+
+// Parses the OpenAPI schema given as JSON
+func ParseSwagger(src Source) (*spec.Swagger, error) {
+	content, err := src.Read()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalSwagger(content)
+}
+
+// Unmarshals the schema from JSON, or from YAML as schemagen writes it with
+// --format yaml, Swagger 1.2 documents are converted to OpenAPI first
+func unmarshalSwagger(content []byte) (*spec.Swagger, error) {
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] != '{' {
+		var tree interface{}
+		if err := yaml.Unmarshal(content, &tree); err != nil {
+			return nil, fmt.Errorf("error unmarshalling in OpenAPI definition: %v", err)
+		}
+		var err error
+		if content, err = json.Marshal(tree); err != nil {
+			return nil, fmt.Errorf("error unmarshalling in OpenAPI definition: %v", err)
+		}
+	}
+
+	content, _, err := convertSwagger12(content)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling in OpenAPI definition: %v", err)
+	}
+
+	api := &spec.Swagger{}
+	if err := json.Unmarshal(content, api); err != nil {
+		return nil, fmt.Errorf("error unmarshalling in OpenAPI definition: %v", err)
+	}
+	if api.Definitions == nil {
+		api.Definitions = make(spec.Definitions)
+	}
+	return api, nil
+}
+
+func parseUpstream(kind string, src Source, cache *SchemaCache) (*spec.Swagger, error) {
+	parse := ParseSwagger
+	if cache != nil {
+		parse = cache.Parse
+	}
+	api, err := parse(src)
+	if err != nil {
+		return nil, &SourceError{Kind: kind, Name: src.Name, Err: err}
+	}
+	return api, nil
+}
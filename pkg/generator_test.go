@@ -0,0 +1,130 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGeneratorReaderSources(t *testing.T) {
+	kedge, err := ReaderSource("types.go", strings.NewReader(testKedgeSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubernetes, err := ReaderSource("swagger.json", strings.NewReader(testKubernetesSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []Diagnostic
+	g, err := NewGenerator(GeneratorOptions{
+		Kedge:       []Source{kedge},
+		Kubernetes:  kubernetes,
+		Prune:       true,
+		Diagnostics: func(d Diagnostic) { diags = append(diags, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := g.Generate()
+	if err != nil {
+		t.Fatalf("could not generate: %v", err)
+	}
+	if len(diags) > 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+
+	expected := []string{
+		"io.k8s.api.core.v1.ContainerPort", "io.k8s.api.core.v1.Probe",
+		"io.kedge.App", "io.kedge.ContainerSpec", "io.kedge.DeploymentSpecMod", "io.kedge.JobSpecMod",
+	}
+	if keys := sortedSchemaKeys(api.Definitions); strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected definitions %v, got %v", expected, keys)
+	}
+	// the fields of the embedded upstream container are injected
+	container := api.Definitions["io.kedge.ContainerSpec"]
+	for _, field := range []string{"health", "name", "image", "ports"} {
+		if _, ok := container.Properties[field]; !ok {
+			t.Errorf("expected io.kedge.ContainerSpec to have field %q, got %v", field, sortedSchemaKeys(container.Properties))
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	kedge := Source{Name: "types.go", Content: []byte(testKedgeSpec)}
+	kubernetes := Source{Name: "swagger.json", Content: []byte(testKubernetesSchema)}
+
+	tests := []struct {
+		name  string
+		opts  GeneratorOptions
+		check func(err error) bool
+	}{
+		{
+			"kubernetes schema missing",
+			GeneratorOptions{Kedge: []Source{kedge}, Kubernetes: FileSource("does-not-exist.json")},
+			func(err error) bool {
+				var source *SourceError
+				var path *os.PathError
+				return errors.As(err, &source) && source.Kind == UpstreamKubernetes && errors.As(err, &path)
+			},
+		},
+		{
+			"kubernetes schema not JSON",
+			GeneratorOptions{Kedge: []Source{kedge}, Kubernetes: Source{Name: "swagger.json", Content: []byte("{")}},
+			func(err error) bool {
+				var source *SourceError
+				return errors.As(err, &source) && source.Name == "swagger.json"
+			},
+		},
+		{
+			"kedge spec not Go",
+			GeneratorOptions{Kedge: []Source{{Name: "types.go", Content: []byte("package spec\n\ntype {")}}, Kubernetes: kubernetes},
+			func(err error) bool {
+				var parse *ParseError
+				return errors.As(err, &parse) && parse.File == "types.go" && parse.Line == 3
+			},
+		},
+		{
+			"root not defined",
+			GeneratorOptions{Kedge: []Source{kedge}, Kubernetes: kubernetes, Roots: []string{"io.kedge.Missing"}},
+			func(err error) bool {
+				var notFound *DefinitionNotFoundError
+				return errors.As(err, &notFound) && notFound.Key == "io.kedge.Missing"
+			},
+		},
+	}
+
+	for _, test := range tests {
+		g, err := NewGenerator(test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		_, err = g.Generate()
+		if err == nil || !test.check(err) {
+			t.Errorf("%s: unexpected error %#v", test.name, err)
+		}
+	}
+
+	if _, err := NewGenerator(GeneratorOptions{Kubernetes: kubernetes}); !errors.Is(err, ErrNoKedgeSources) {
+		t.Errorf("expected no Kedge sources to be an error, got %v", err)
+	}
+	if _, err := NewGenerator(GeneratorOptions{Kedge: []Source{kedge}}); !errors.Is(err, ErrNoKubernetesSchema) {
+		t.Errorf("expected no Kubernetes schema to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,421 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// First line of generated Go files, so that tools know not to edit them
+const goGeneratedComment = "// Code generated by schemagen gen-go. DO NOT EDIT."
+
+// Width that comments of generated Go code are wrapped at
+const goCommentWidth = 80
+
+// Comments starting with these are not descriptions, so descriptions with
+// lines starting with them are written in the schema marker instead
+var goCommentMarkers = []string{"kedgeSpec:", "ref:", "k8s:", "+"}
+
+// Returns Go source code of package pkgName with a struct for every
+// definition of keys and for every definition they refer to, keys default
+// to the Kedge definitions except AppKey. Structs and their fields follow
+// the conventions of Kedge spec: 'kedgeSpec:' gives the key of the struct,
+// references are given with 'ref:', or 'k8s:' for upstream definitions, and
+// fields are required unless marked '+optional'. Fields of upstream
+// definitions injected into Kedge definitions are fields of their own, and
+// keywords Go types can't express are given with SchemaMarker. Definitions
+// that are not objects, like quantities that are strings, have no struct,
+// fields referring to them have the type of their values. The code is parsed
+// back and it is an error if it does not describe the same definitions.
+func GenerateGo(defs spec.Definitions, keys []string, pkgName string) ([]byte, error) {
+	if !isIdentifier(pkgName) || strings.Contains(pkgName, "$") {
+		return nil, fmt.Errorf("package name %q is not a Go identifier", pkgName)
+	}
+	if len(keys) == 0 {
+		for _, k := range KedgeRoots(defs) {
+			// it is made by schemagen from the controllers, not a struct
+			if k != AppKey {
+				keys = append(keys, k)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no definitions to generate Go types for")
+	}
+	selected, err := PruneDefinitions(defs, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := sortedSchemaKeys(selected)
+	types := &goTypes{defs: selected, names: GoTypeNames(sorted)}
+	// definitions the parsed code has to describe
+	structs := make(spec.Definitions, len(selected))
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\npackage %s\n", goGeneratedComment, pkgName)
+	for _, key := range sorted {
+		if !goIsStruct(selected[key]) {
+			continue
+		}
+		structs[key] = selected[key]
+		if err := types.writeStruct(&b, key, selected[key]); err != nil {
+			return nil, errors.Wrapf(err, "definition %q", key)
+		}
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("none of the definitions %v are objects", keys)
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "generated Go code is invalid")
+	}
+
+	parsed, _, err := ParseKedgeSources([]Source{{Name: "generated.go", Content: src}})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse generated Go code")
+	}
+	if c := ChangedDefinitions(structs, parsed); !c.Empty() {
+		return nil, fmt.Errorf("generated Go code does not describe the same definitions, changed: %v, missing: %v, extra: %v", c.Changed, c.Removed, c.Added)
+	}
+	return src, nil
+}
+
+// Returns the names of Go types of definitions, the last part of the key
+// e.g. 'ServicePort' for 'io.kedge.ServicePort', with the parts before it in
+// front e.g. 'KedgeServicePort' and 'V1ServicePort' when more keys would
+// get the same name
+func GoTypeNames(keys []string) map[string]string {
+	parts := make(map[string][]string, len(keys))
+	// number of the parts of the key the name is made of
+	n := make(map[string]int, len(keys))
+	for _, k := range keys {
+		parts[k] = strings.Split(k, ".")
+		n[k] = 1
+	}
+	name := func(k string) string {
+		p := parts[k]
+		return goName(strings.Join(p[len(p)-n[k]:], "."))
+	}
+
+	for changed := true; changed; {
+		changed = false
+		byName := make(map[string][]string)
+		for _, k := range keys {
+			byName[name(k)] = append(byName[name(k)], k)
+		}
+		for _, same := range byName {
+			if len(same) < 2 {
+				continue
+			}
+			for _, k := range same {
+				if n[k] < len(parts[k]) {
+					n[k]++
+					changed = true
+				}
+			}
+		}
+	}
+
+	// keys that differ only in case or punctuation still need their own names
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	names := make(map[string]string, len(keys))
+	used := make(map[string]bool, len(keys))
+	for _, k := range sorted {
+		unique := name(k)
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s%d", name(k), i)
+		}
+		used[unique] = true
+		names[k] = unique
+	}
+	return names
+}
+
+// Returns the exported Go identifier made of the words in s
+// e.g. 'PortMappings' for 'portMappings' and 'XKubernetesPatch' for
+// 'x-kubernetes-patch'
+func goName(s string) string {
+	var b bytes.Buffer
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// goTypes has the definitions Go types are written for
+type goTypes struct {
+	defs spec.Definitions
+	// names of the structs by the keys of their definitions
+	names map[string]string
+}
+
+// Returns true if values of the definition are objects, which are written as
+// structs
+func goIsStruct(def spec.Schema) bool {
+	return len(def.Properties) > 0 || def.Type.Contains("object") && def.AdditionalProperties == nil
+}
+
+// Writes the struct of the definition with the given key
+func (t *goTypes) writeStruct(b *bytes.Buffer, key string, def spec.Schema) error {
+	var fields bytes.Buffer
+	var required []string
+	used := make(map[string]bool)
+	for _, name := range sortedSchemaKeys(def.Properties) {
+		if name == "" || strings.ContainsAny(name, "\",` ") {
+			return fmt.Errorf("property %q can't be given in a json tag", name)
+		}
+		isRequired := false
+		for _, r := range def.Required {
+			isRequired = isRequired || r == name
+		}
+		if isRequired {
+			required = append(required, name)
+		}
+
+		fieldName := goName(name)
+		for i := 2; used[fieldName]; i++ {
+			fieldName = fmt.Sprintf("%s%d", goName(name), i)
+		}
+		used[fieldName] = true
+		if err := t.writeField(&fields, fieldName, name, def.Properties[name], isRequired); err != nil {
+			return errors.Wrapf(err, "property %q", name)
+		}
+	}
+
+	// what is parsed from the struct without the schema marker
+	comment := goComment(def.Description)
+	parsed := spec.Schema{}
+	if comment != "" {
+		parsed.Description = def.Description
+	}
+	parsed.Required = required
+	// properties are the same already, since every field is
+	parsed.Properties = def.Properties
+	marker, err := schemaMarker(parsed, def)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(b, "\n%s", comment)
+	if marker != "" {
+		fmt.Fprintf(b, "// %s\n", marker)
+	}
+	fmt.Fprintf(b, "// kedgeSpec: %s\ntype %s struct {\n%s}\n", key, t.names[key], fields.String())
+	return nil
+}
+
+// Writes the field of the property
+func (t *goTypes) writeField(b *bytes.Buffer, fieldName, name string, s spec.Schema, required bool) error {
+	typ, ref := t.goType(s, true)
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return err
+	}
+	fieldtype, format, err := GetStructFieldType(expr)
+	if err != nil {
+		return err
+	}
+	comment := goComment(s.Description)
+	desc := ""
+	if comment != "" {
+		desc = s.Description
+	}
+	parsed, err := CreateSchema(fieldtype, format, desc, ref)
+	if err != nil {
+		return err
+	}
+	marker, err := schemaMarker(parsed, s)
+	if err != nil {
+		return err
+	}
+
+	b.WriteString(comment)
+	switch {
+	case ref == "":
+	case strings.HasPrefix(ref, KedgeKeyPrefix):
+		fmt.Fprintf(b, "// ref: %s\n", ref)
+	default:
+		fmt.Fprintf(b, "// k8s: %s\n", ref)
+	}
+	if marker != "" {
+		fmt.Fprintf(b, "// %s\n", marker)
+	}
+	if !required {
+		fmt.Fprintf(b, "// +optional\n")
+	} else if comment == "" && ref == "" && marker == "" {
+		// fields without comments are optional
+		fmt.Fprintf(b, "// %s\n", RequiredMarker)
+	}
+
+	tag := name
+	if !required {
+		tag += ",omitempty"
+	}
+	fmt.Fprintf(b, "%s %s `json:\"%s\"`\n", fieldName, typ, tag)
+	return nil
+}
+
+// Returns the Go type of values of the schema and the key of the definition
+// it refers to, which is given in the reference comment. Pointers are used
+// for references of fields since that is what the parser takes as
+// references. Definitions that are not objects, like times that are
+// strings, have no struct, so values of them have the type of the
+// definition instead.
+func (t *goTypes) goType(s spec.Schema, field bool) (string, string) {
+	if key := RefKey(s.Ref); key != "" {
+		def := t.defs[key]
+		if !goIsStruct(def) {
+			// only one level, so that references in cycles end
+			typ, _ := t.goType(spec.Schema{SchemaProps: spec.SchemaProps{
+				Type:                 def.Type,
+				Format:               def.Format,
+				Properties:           def.Properties,
+				Items:                def.Items,
+				AdditionalProperties: def.AdditionalProperties,
+			}}, field)
+			return typ, key
+		}
+		if field {
+			return "*" + t.names[key], key
+		}
+		return t.names[key], key
+	}
+	if s.Ref.String() != "" {
+		return "interface{}", ""
+	}
+
+	if len(s.Type) != 1 {
+		if len(s.Type) == 0 && len(s.Properties) > 0 {
+			return "map[string]interface{}", ""
+		}
+		return "interface{}", ""
+	}
+	switch s.Type[0] {
+	case "string":
+		// given as either integer or string
+		if s.Format == "int-or-string" {
+			return "interface{}", ""
+		}
+		return "string", ""
+	case "boolean":
+		return "bool", ""
+	case "integer":
+		switch s.Format {
+		case "int32":
+			return "int32", ""
+		case "":
+			return "int", ""
+		}
+		return "int64", ""
+	case "number":
+		if s.Format == "float" {
+			return "float32", ""
+		}
+		return "float64", ""
+	case "array":
+		if s.Items == nil || s.Items.Schema == nil {
+			return "[]interface{}", ""
+		}
+		elem, _ := t.goType(*s.Items.Schema, false)
+		return "[]" + elem, RefKey(s.Items.Schema.Ref)
+	case "object":
+		if s.AdditionalProperties == nil || s.AdditionalProperties.Schema == nil {
+			return "map[string]interface{}", ""
+		}
+		elem, _ := t.goType(*s.AdditionalProperties.Schema, false)
+		return "map[string]" + elem, RefKey(s.AdditionalProperties.Schema.Ref)
+	}
+	return "interface{}", ""
+}
+
+// Returns the description as comment lines, blank if it can't be given as
+// comment and parsed back the same
+func goComment(desc string) string {
+	if desc == "" || strings.Join(strings.Fields(desc), " ") != desc {
+		return ""
+	}
+	lines := wrapText(desc, "// ", goCommentWidth)
+	for _, l := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+		l = strings.TrimPrefix(l, "// ")
+		for _, m := range goCommentMarkers {
+			if strings.HasPrefix(l, m) {
+				return ""
+			}
+		}
+	}
+	return lines
+}
+
+// Returns the schema marker with the keywords that need to be changed in
+// the parsed schema to get the wanted one, blank if they are the same
+func schemaMarker(parsed, wanted spec.Schema) (string, error) {
+	p, err := jsonTree(parsed)
+	if err != nil {
+		return "", err
+	}
+	w, err := jsonTree(wanted)
+	if err != nil {
+		return "", err
+	}
+	pm, _ := p.(map[string]interface{})
+	wm, _ := w.(map[string]interface{})
+
+	keywords := make(map[string]interface{})
+	for k, v := range wm {
+		if !reflect.DeepEqual(pm[k], v) {
+			keywords[k] = v
+		}
+	}
+	for k := range pm {
+		if _, ok := wm[k]; !ok {
+			keywords[k] = nil
+		}
+	}
+	if len(keywords) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(keywords)
+	if err != nil {
+		return "", err
+	}
+	return SchemaMarker + " " + string(b), nil
+}
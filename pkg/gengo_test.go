@@ -0,0 +1,67 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateGoPrimitiveDefinitions(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.Resources": {
+			"type": "object",
+			"required": ["cpu"],
+			"properties": {
+				"cpu": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.api.resource.Quantity"},
+				"port": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"},
+				"limits": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.api.resource.Quantity"}},
+				"meta": {"$ref": "#/definitions/io.k8s.ObjectMeta"}
+			}
+		},
+		"io.k8s.ObjectMeta": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"io.k8s.apimachinery.pkg.api.resource.Quantity": {"type": "string"},
+		"io.k8s.apimachinery.pkg.util.intstr.IntOrString": {"type": "string", "format": "int-or-string"}
+	}`)
+
+	src, err := GenerateGo(defs, []string{"io.kedge.Resources"}, "kedge")
+	if err != nil {
+		t.Fatalf("could not generate Go: %v", err)
+	}
+	code := string(src)
+	for _, s := range []string{
+		"type Resources struct {",
+		"type ObjectMeta struct {",
+		"Cpu string `json:\"cpu\"`",
+		"Port interface{} `json:\"port,omitempty\"`",
+		"Limits map[string]string `json:\"limits,omitempty\"`",
+		"Meta *ObjectMeta `json:\"meta,omitempty\"`",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", s, code)
+		}
+	}
+	for _, s := range []string{"type Quantity", "type IntOrString"} {
+		if strings.Contains(code, s) {
+			t.Errorf("expected no struct for primitive definition %q, got:\n%s", s, code)
+		}
+	}
+
+	if _, err := GenerateGo(defs, []string{"io.k8s.apimachinery.pkg.api.resource.Quantity"}, "kedge"); err == nil {
+		t.Errorf("expected error generating Go for only primitive definitions")
+	}
+}
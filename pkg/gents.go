@@ -0,0 +1,263 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// First line of generated TypeScript files, so that tools know not to edit
+// them
+const tsGeneratedComment = "// Code generated by schemagen gen-ts. DO NOT EDIT."
+
+// Width that JSDoc comments of generated TypeScript are wrapped at
+const tsCommentWidth = 80
+
+// Returns TypeScript declarations, the content of a '.d.ts' file, with a
+// type for every definition of keys and for every definition they refer to,
+// keys default to all the Kedge definitions. Objects are interfaces whose
+// fields are optional unless they are required, enums are unions of their
+// values, 'int-or-string' is 'number | string' and descriptions are JSDoc.
+// Types are named the same as the structs written by GenerateGo.
+func GenerateTypeScript(defs spec.Definitions, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		keys = KedgeRoots(defs)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no definitions to generate TypeScript types for")
+	}
+	selected, err := PruneDefinitions(defs, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := sortedSchemaKeys(selected)
+	w := &tsWriter{names: GoTypeNames(sorted)}
+	w.b.WriteString(tsGeneratedComment + "\n")
+	for _, key := range sorted {
+		w.writeDefinition(key, selected[key])
+	}
+	return w.b.Bytes(), nil
+}
+
+// tsWriter writes TypeScript declarations
+type tsWriter struct {
+	b bytes.Buffer
+	// names of the types by the keys of their definitions
+	names map[string]string
+}
+
+// Writes the declaration of the definition, an interface if it is an object
+// with properties and a type alias otherwise
+func (w *tsWriter) writeDefinition(key string, s spec.Schema) {
+	w.b.WriteString("\n")
+	w.writeDoc(s.Description, "")
+	name := w.names[key]
+	if tsIsInterface(s) {
+		fmt.Fprintf(&w.b, "export interface %s ", name)
+		w.writeObject(s, "")
+		w.b.WriteString("\n")
+		return
+	}
+	fmt.Fprintf(&w.b, "export type %s = %s;\n", name, w.typeOf(s, ""))
+}
+
+// Returns true if the schema is a plain object that can be an interface
+func tsIsInterface(s spec.Schema) bool {
+	return len(s.Properties) > 0 && len(s.AllOf) == 0 && len(s.OneOf) == 0 && len(s.AnyOf) == 0 &&
+		len(s.Enum) == 0 && s.Ref.String() == "" && !tsNullable(s)
+}
+
+// Returns the TypeScript type of values of the schema, nested objects are
+// written indented by indent
+func (w *tsWriter) typeOf(s spec.Schema, indent string) string {
+	t := w.baseTypeOf(s, indent)
+	if tsNullable(s) && t != "null" && t != "unknown" {
+		t += " | null"
+	}
+	return t
+}
+
+func (w *tsWriter) baseTypeOf(s spec.Schema, indent string) string {
+	if key := RefKey(s.Ref); key != "" {
+		return w.names[key]
+	}
+	if s.Ref.String() != "" {
+		return "unknown"
+	}
+	if len(s.Enum) > 0 {
+		var values []string
+		for _, v := range s.Enum {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return "unknown"
+			}
+			values = append(values, string(b))
+		}
+		return strings.Join(values, " | ")
+	}
+	if s.Format == "int-or-string" {
+		return "number | string"
+	}
+
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		var types []string
+		for _, o := range append(append([]spec.Schema{}, s.OneOf...), s.AnyOf...) {
+			types = append(types, tsParens(w.typeOf(o, indent)))
+		}
+		return w.withAllOf(s, strings.Join(types, " | "), indent)
+	}
+	if len(s.AllOf) > 0 {
+		return w.withAllOf(s, "", indent)
+	}
+
+	var types []string
+	for _, t := range s.Type {
+		types = append(types, w.typeOfType(s, t, indent))
+	}
+	if len(types) == 0 {
+		if len(s.Properties) > 0 || s.AdditionalProperties != nil {
+			return w.typeOfType(s, "object", indent)
+		}
+		return "unknown"
+	}
+	return strings.Join(types, " | ")
+}
+
+// Returns the type of the schema when its values are of JSON type t
+func (w *tsWriter) typeOfType(s spec.Schema, t, indent string) string {
+	switch t {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "array":
+		if s.Items == nil {
+			return "unknown[]"
+		}
+		if s.Items.Schema != nil {
+			return tsParens(w.typeOf(*s.Items.Schema, indent)) + "[]"
+		}
+		// tuples
+		var items []string
+		for _, item := range s.Items.Schemas {
+			items = append(items, w.typeOf(item, indent))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case "object":
+		if len(s.Properties) == 0 {
+			if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				return "{ [key: string]: " + w.typeOf(*s.AdditionalProperties.Schema, indent) + " }"
+			}
+			return "{ [key: string]: unknown }"
+		}
+		var b bytes.Buffer
+		w.b, b = b, w.b
+		w.writeObject(s, indent)
+		w.b, b = b, w.b
+		return b.String()
+	}
+	return "unknown"
+}
+
+// Returns the intersection of the schemas of allOf, the properties of the
+// schema itself and the given type
+func (w *tsWriter) withAllOf(s spec.Schema, t, indent string) string {
+	var types []string
+	for _, a := range s.AllOf {
+		types = append(types, tsParens(w.typeOf(a, indent)))
+	}
+	if len(s.Properties) > 0 {
+		types = append(types, w.typeOfType(s, "object", indent))
+	}
+	if t != "" {
+		types = append(types, tsParens(t))
+	}
+	if len(types) == 0 {
+		return "unknown"
+	}
+	return strings.Join(types, " & ")
+}
+
+// Writes the properties of the object in braces, with JSDoc of their
+// descriptions
+func (w *tsWriter) writeObject(s spec.Schema, indent string) {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	inner := indent + "  "
+	w.b.WriteString("{\n")
+	for _, name := range sortedSchemaKeys(s.Properties) {
+		p := s.Properties[name]
+		w.writeDoc(p.Description, inner)
+		field := name
+		if !isIdentifier(name) {
+			field = fmt.Sprintf("%q", name)
+		}
+		if !required[name] {
+			field += "?"
+		}
+		fmt.Fprintf(&w.b, "%s%s: %s;\n", inner, field, w.typeOf(p, inner))
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		// other properties can be anything the listed ones are
+		fmt.Fprintf(&w.b, "%s[key: string]: unknown;\n", inner)
+	}
+	w.b.WriteString(indent + "}")
+}
+
+// Writes the description as JSDoc comment
+func (w *tsWriter) writeDoc(desc, indent string) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return
+	}
+	desc = strings.Replace(desc, "*/", "*\\/", -1)
+	w.b.WriteString(indent + "/**\n")
+	for i, paragraph := range strings.Split(desc, "\n") {
+		if i > 0 {
+			w.b.WriteString(indent + " *\n")
+		}
+		w.b.WriteString(wrapText(paragraph, indent+" * ", tsCommentWidth))
+	}
+	w.b.WriteString(indent + " */\n")
+}
+
+// Returns true if the schema also allows null
+func tsNullable(s spec.Schema) bool {
+	nullable, _ := s.Extensions["x-nullable"].(bool)
+	return nullable
+}
+
+// Returns the type in parentheses if it is a union or intersection, so that
+// it can be used in one
+func tsParens(t string) string {
+	if strings.ContainsAny(t, "|&") && !strings.HasPrefix(t, "{") {
+		return "(" + t + ")"
+	}
+	return t
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import "testing"
+
+func TestGenerateTypeScript(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {
+			"description": "App is a Kedge file.\nIt has one or more containers.",
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "description": "Name of the app, comments end with */"},
+				"containers": {"type": "array", "items": {"$ref": "#/definitions/io.kedge.Container"}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"limits": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.Quantity"}},
+				"annotations": {"type": "object"},
+				"replicas": {"type": "integer", "x-nullable": true},
+				"meta": {"$ref": "#/definitions/io.k8s.ObjectMeta", "x-nullable": true},
+				"policy": {"type": "string", "enum": ["Always", "Never"]},
+				"port": {"type": "string", "format": "int-or-string"},
+				"x-extra": {"type": "boolean"}
+			}
+		},
+		"io.kedge.Container": {
+			"allOf": [{"$ref": "#/definitions/io.k8s.Container"}],
+			"properties": {"health": {"type": "object", "properties": {"path": {"type": "string"}}}}
+		},
+		"io.kedge.Volume": {
+			"oneOf": [
+				{"type": "object", "required": ["configMap"], "properties": {"configMap": {"type": "string"}}},
+				{"type": "object", "required": ["secret"], "properties": {"secret": {"type": "string"}}}
+			],
+			"x-nullable": true
+		},
+		"io.k8s.Container": {
+			"type": "object",
+			"required": ["image"],
+			"properties": {"image": {"type": "string"}, "args": {"type": "array", "items": {"type": ["string", "number"]}}},
+			"additionalProperties": {"type": "string"}
+		},
+		"io.k8s.ObjectMeta": {"type": "object", "properties": {"name": {"type": "string"}}},
+		"io.k8s.Quantity": {"type": "string"},
+		"io.k8s.Unused": {"type": "object", "properties": {"name": {"type": "string"}}}
+	}`)
+
+	src, err := GenerateTypeScript(defs, nil)
+	if err != nil {
+		t.Fatalf("could not generate TypeScript: %v", err)
+	}
+	assertGolden(t, "types.d.ts", src)
+
+	if _, err := GenerateTypeScript(defs, []string{"io.kedge.Missing"}); err == nil {
+		t.Errorf("expected error generating TypeScript for missing definition")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+// Golden files in testdata are written again with 'go test -update'
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Compares actual to the golden file in testdata, or writes it there when
+// updating
+func assertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	filename := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(filename, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("could not read golden file: %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s: expected\n%s\ngot\n%s", filename, expected, actual)
+	}
+}
+
+// Returns the definitions of the JSON object of definitions
+func testDefinitions(t *testing.T, definitions string) spec.Definitions {
+	t.Helper()
+	var defs spec.Definitions
+	if err := json.Unmarshal([]byte(definitions), &defs); err != nil {
+		t.Fatalf("could not parse definitions: %v", err)
+	}
+	return defs
+}
+
+// Returns the schema of the JSON
+func testSchema(t *testing.T, schema string) spec.Schema {
+	t.Helper()
+	var s spec.Schema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		t.Fatalf("could not parse schema: %v", err)
+	}
+	return s
+}
+
+// Kedge spec with a definition for every controller but deploymentconfig,
+// as used by the tests that generate the whole schema
+const testKedgeSpec = `package spec
+
+import (
+	api_v1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// ContainerSpec is a container
+// kedgeSpec: io.kedge.ContainerSpec
+type Container struct {
+	// Health probe
+	// ref: io.k8s.api.core.v1.Probe
+	// +optional
+	Health *api_v1.Probe ` + "`json:\"health,omitempty\"`" + `
+	// k8s: io.k8s.api.core.v1.Container
+	api_v1.Container ` + "`json:\",inline\"`" + `
+}
+
+// DeploymentSpecMod is a deployment
+// kedgeSpec: io.kedge.DeploymentSpecMod
+type DeploymentSpecMod struct {
+	// Name of app
+	Name string ` + "`json:\"name\"`" + `
+	// +optional
+	Controller string ` + "`json:\"controller,omitempty\"`" + `
+	// List of containers
+	// ref: io.kedge.ContainerSpec
+	Containers []Container ` + "`json:\"containers\"`" + `
+	// +optional
+	Replicas *int32 ` + "`json:\"replicas,omitempty\"`" + `
+}
+
+// JobSpecMod is a job
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	// Name of app
+	Name string ` + "`json:\"name\"`" + `
+	Controller string ` + "`json:\"controller\"`" + `
+	// List of containers
+	// ref: io.kedge.ContainerSpec
+	Containers []Container ` + "`json:\"containers\"`" + `
+}
+`
+
+// Kubernetes schema with the definitions testKedgeSpec refers to
+const testKubernetesSchema = `{
+	"swagger": "2.0",
+	"info": {"title": "Kubernetes", "version": "v1.7.0"},
+	"paths": {},
+	"definitions": {
+		"io.k8s.api.core.v1.Container": {
+			"description": "A single application container",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string"},
+				"image": {"type": "string"},
+				"ports": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.api.core.v1.ContainerPort"}}
+			}
+		},
+		"io.k8s.api.core.v1.ContainerPort": {
+			"required": ["containerPort"],
+			"properties": {"containerPort": {"type": "integer", "format": "int32"}}
+		},
+		"io.k8s.api.core.v1.Probe": {
+			"properties": {"initialDelaySeconds": {"type": "integer", "format": "int32"}}
+		},
+		"io.k8s.api.core.v1.Unused": {
+			"properties": {"name": {"type": "string"}}
+		}
+	}
+}`
+
+// Writes testKedgeSpec and testKubernetesSchema to types.go and
+// swagger.json in dir and returns the config generating from them, without
+// OpenShift schema
+func testConfig(t *testing.T, dir string) *Config {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Kedge.Sources = []string{filepath.Join(dir, "types.go")}
+	cfg.Upstream.Kubernetes = filepath.Join(dir, "swagger.json")
+	cfg.Upstream.OpenShift = ""
+	if err := ioutil.WriteFile(cfg.Kedge.Sources[0], []byte(testKedgeSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cfg.Upstream.Kubernetes, []byte(testKubernetesSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// Writes the Kubernetes schema of a newer release to dir, its container has
+// a 'workingDir' field that testKubernetesSchema doesn't, and returns its path
+func testNewerKubernetesSchema(t *testing.T, dir string) string {
+	t.Helper()
+	file := filepath.Join(dir, "swagger-newer.json")
+	content := strings.Replace(testKubernetesSchema, `"image": {"type": "string"},`, `"image": {"type": "string"}, "workingDir": {"type": "string"},`, 1)
+	if content == testKubernetesSchema {
+		t.Fatal("could not add field to the container")
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
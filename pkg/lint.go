@@ -0,0 +1,313 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Names of the lint rules
+const (
+	RuleMissingKedgeSpec   = "missing-kedgespec"
+	RuleDuplicateKey       = "duplicate-key"
+	RuleMissingDescription = "missing-description"
+	RuleUnresolvedRef      = "unresolved-ref"
+	RuleMissingK8sComment  = "missing-k8s-comment"
+	RuleUnusedDefinition   = "unused-definition"
+)
+
+// All the lint rules, see conversion.md for the conventions they check
+var LintRules = []Rule{
+	{RuleMissingKedgeSpec, SeverityError, "structs used as field types of Kedge definitions need a 'kedgeSpec:' key"},
+	{RuleDuplicateKey, SeverityError, "every 'kedgeSpec:' key is defined by only one struct"},
+	{RuleMissingDescription, SeverityWarning, "Kedge definitions and their fields have descriptions"},
+	{RuleUnresolvedRef, SeverityError, "'k8s:' and 'ref:' comments refer to keys that are defined"},
+	{RuleMissingK8sComment, SeverityError, "embedded types of other packages have a 'k8s:' comment to inject them from"},
+	{RuleUnusedDefinition, SeverityWarning, "Kedge definitions are reachable from the root definition through references of the generated schema"},
+}
+
+// Returns the rules to run, all of them unless some are enabled explicitly,
+// minus the disabled ones
+func SelectLintRules(enable, disable []string) ([]Rule, error) {
+	known := make(map[string]bool)
+	for _, r := range LintRules {
+		known[r.Name] = true
+	}
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+	}
+
+	enabled, disabled := stringSet(enable), stringSet(disable)
+	var rules []Rule
+	for _, r := range LintRules {
+		if (len(enable) == 0 || enabled[r.Name]) && !disabled[r.Name] {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// lintStruct is a struct type declared in Kedge spec sources
+type lintStruct struct {
+	name  string
+	key   string
+	desc  string
+	pos   token.Pos
+	strct *ast.StructType
+}
+
+type linter struct {
+	fset    *token.FileSet
+	rules   map[string]Rule
+	aliases map[string]string
+	// all the structs in the order they are declared and by their name
+	structs []*lintStruct
+	byName  map[string]*lintStruct
+	diags   []Diagnostic
+}
+
+// Checks the Kedge spec sources of the generator against the conventions
+// of the given rules and returns the problems found sorted by position.
+// Definitions are generated to check references and reachability, so
+// generation errors are returned as errors.
+func Lint(g *Generator, rules []Rule) ([]Diagnostic, error) {
+	fset, nodes, err := parseKedgeFiles(g.opts.Kedge)
+	if err != nil {
+		return nil, err
+	}
+
+	// all the definitions are needed whether pruning is asked for or not
+	opts := g.opts
+	opts.Prune, opts.Roots = false, nil
+	// lint rules cover the same problems as the ones generation reports
+	opts.Diagnostics = func(Diagnostic) {}
+	api, err := (&Generator{opts: opts}).Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		fset:    fset,
+		rules:   make(map[string]Rule),
+		aliases: g.opts.Aliases,
+		byName:  make(map[string]*lintStruct),
+	}
+	for _, r := range rules {
+		l.rules[r.Name] = r
+	}
+	for _, node := range nodes {
+		for _, decl := range node.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, s := range genDecl.Specs {
+				strct, ok := TypeSpecToStruct(s)
+				if !ok {
+					continue
+				}
+				key, desc := ParseStructComments(genDecl.Doc)
+				ls := &lintStruct{
+					name:  s.(*ast.TypeSpec).Name.Name,
+					key:   key,
+					desc:  desc,
+					pos:   s.(*ast.TypeSpec).Name.Pos(),
+					strct: strct,
+				}
+				l.structs = append(l.structs, ls)
+				l.byName[ls.name] = ls
+			}
+		}
+	}
+
+	l.checkDuplicateKeys()
+	l.checkStructs(api.Definitions)
+	l.checkUnused(api.Definitions)
+
+	SortDiagnostics(l.diags)
+	return l.diags, nil
+}
+
+// Adds the diagnostic if the rule is enabled
+func (l *linter) report(rule string, pos token.Pos, format string, args ...interface{}) {
+	r, ok := l.rules[rule]
+	if !ok {
+		return
+	}
+	p := l.fset.Position(pos)
+	l.diags = append(l.diags, Diagnostic{
+		Rule:     rule,
+		Severity: r.Severity,
+		Message:  fmt.Sprintf(format, args...),
+		File:     p.Filename,
+		Line:     p.Line,
+		Column:   p.Column,
+	})
+}
+
+func (l *linter) checkDuplicateKeys() {
+	first := make(map[string]*lintStruct)
+	for _, s := range l.structs {
+		if s.key == "" {
+			continue
+		}
+		if f, ok := first[s.key]; ok {
+			l.report(RuleDuplicateKey, s.pos, "key %q of %s is already used by %s at %s", s.key, s.name, f.name, l.fset.Position(f.pos))
+			continue
+		}
+		first[s.key] = s
+	}
+}
+
+// Walks the structs of Kedge definitions and the structs embedded in them,
+// and checks their fields
+func (l *linter) checkStructs(defs spec.Definitions) {
+	var queue []*lintStruct
+	visited := make(map[*lintStruct]bool)
+	for _, s := range l.structs {
+		if s.key != "" {
+			queue = append(queue, s)
+			visited[s] = true
+			if s.desc == "" {
+				l.report(RuleMissingDescription, s.pos, "definition %q of %s has no description", s.key, s.name)
+			}
+		}
+	}
+	unkeyed := make(map[*lintStruct]bool)
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		for _, f := range s.strct.Fields.List {
+			desc, ref, _ := ParseStructFieldComments(f.Doc)
+			embedded := len(f.Names) == 0
+
+			if ref != "" {
+				key := ref
+				if alias, ok := l.aliases[ref]; ok {
+					key = alias
+				}
+				if _, ok := defs[key]; !ok {
+					l.report(RuleUnresolvedRef, f.Pos(), "%q is not defined by Kedge spec or upstream schemas", ref)
+				}
+			}
+
+			switch t := baseType(f.Type).(type) {
+			case *ast.SelectorExpr:
+				if embedded && ref == "" {
+					l.report(RuleMissingK8sComment, f.Pos(), "embedded %s has no 'k8s:' comment, so nothing is injected from it", typeString(t))
+				}
+			case *ast.Ident:
+				target, ok := l.byName[t.Name]
+				if !ok {
+					break
+				}
+				if embedded {
+					// fields of embedded structs are part of this definition
+					if target.key == "" && !visited[target] {
+						visited[target] = true
+						queue = append(queue, target)
+					}
+					continue
+				}
+				if target.key == "" && !unkeyed[target] {
+					unkeyed[target] = true
+					l.report(RuleMissingKedgeSpec, target.pos, "struct %s is used by field %s of %s but has no 'kedgeSpec:' key", target.name, f.Names[0].Name, s.name)
+				}
+			}
+
+			if !embedded && desc == "" {
+				l.report(RuleMissingDescription, f.Pos(), "field %s of %s has no description", f.Names[0].Name, s.name)
+			}
+		}
+	}
+}
+
+// Reports Kedge definitions that can't be reached from the root definition,
+// or from the controller definitions if there is no root definition, by
+// following the references of the generated definitions like pruning does,
+// including the ones of injected upstream definitions. Only
+// the first struct of a key is reported, the others are duplicates anyway.
+func (l *linter) checkUnused(defs spec.Definitions) {
+	var roots []string
+	if _, ok := defs[AppKey]; ok {
+		roots = []string{AppKey}
+	} else {
+		for _, c := range Controllers {
+			if _, ok := defs[c.Key]; ok {
+				roots = append(roots, c.Key)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		// without any controller there is nothing to be reachable from
+		return
+	}
+	reachable := reachableKeys(defs, roots)
+
+	seen := make(map[string]bool)
+	for _, s := range l.structs {
+		if s.key == "" || seen[s.key] {
+			continue
+		}
+		seen[s.key] = true
+		if !reachable[s.key] {
+			l.report(RuleUnusedDefinition, s.pos, "definition %q of %s is not reachable from %s", s.key, s.name, strings.Join(roots, ", "))
+		}
+	}
+}
+
+// Returns keys of definitions reachable from roots, references to
+// definitions that don't exist are ignored
+func reachableKeys(defs spec.Definitions, roots []string) map[string]bool {
+	reachable := make(map[string]bool)
+	// roots are only the ones that are defined
+	pruned, _ := pruneDefinitions(defs, roots, func(from, key string) {})
+	for k := range pruned {
+		reachable[k] = true
+	}
+	return reachable
+}
+
+// Returns the type without pointers and slices around it
+func baseType(t ast.Expr) ast.Expr {
+	for {
+		switch v := t.(type) {
+		case *ast.StarExpr:
+			t = v.X
+		case *ast.ArrayType:
+			t = v.Elt
+		default:
+			return t
+		}
+	}
+}
+
+func typeString(s *ast.SelectorExpr) string {
+	if x, ok := s.X.(*ast.Ident); ok {
+		return x.Name + "." + s.Sel.Name
+	}
+	return s.Sel.Name
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Kedge spec that follows all the conventions, the tests add to it what
+// breaks them
+const testLintSpec = `package spec
+
+import (
+	api_v1 "k8s.io/client-go/pkg/api/v1"
+)
+
+// DeploymentSpecMod is a deployment
+// kedgeSpec: io.kedge.DeploymentSpecMod
+type DeploymentSpecMod struct {
+	// Name of app
+	Name string ` + "`json:\"name\"`" + `
+	// Containers of the app
+	// ref: io.kedge.ContainerSpec
+	Containers []ContainerSpec ` + "`json:\"containers\"`" + `
+}
+
+// ContainerSpec is a container
+// kedgeSpec: io.kedge.ContainerSpec
+type ContainerSpec struct {
+	// k8s: io.k8s.api.core.v1.Container
+	api_v1.Container ` + "`json:\",inline\"`" + `
+}
+`
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		// added to the end of testLintSpec
+		source   string
+		expected []string
+	}{
+		{RuleMissingKedgeSpec, `
+// JobSpecMod is a job
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	// Volume of the job
+	// ref: io.kedge.Volume
+	Volume Volume ` + "`json:\"volume\"`" + `
+}
+
+// Volume is a volume
+type Volume struct {
+	// Name of the volume
+	Name string ` + "`json:\"name\"`" + `
+}
+`, []string{
+			`types.go:29:2: error: "io.kedge.Volume" is not defined by Kedge spec or upstream schemas [unresolved-ref]`,
+			`types.go:33:6: error: struct Volume is used by field Volume of JobSpecMod but has no 'kedgeSpec:' key [missing-kedgespec]`,
+		}},
+		{RuleDuplicateKey, `
+// Container is a container too
+// kedgeSpec: io.kedge.ContainerSpec
+type Container struct {
+	// Name of the container
+	Name string ` + "`json:\"name\"`" + `
+}
+`, []string{
+			`types.go:26:6: error: key "io.kedge.ContainerSpec" of Container is already used by ContainerSpec at types.go:19:6 [duplicate-key]`,
+		}},
+		{RuleMissingDescription, `
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	Name string ` + "`json:\"name\"`" + `
+	// Parallelism of the job
+	Parallelism int ` + "`json:\"parallelism\"`" + `
+}
+`, []string{
+			`types.go:25:6: warning: definition "io.kedge.JobSpecMod" of JobSpecMod has no description [missing-description]`,
+			`types.go:26:2: warning: field Name of JobSpecMod has no description [missing-description]`,
+		}},
+		{RuleUnresolvedRef, `
+// JobSpecMod is a job
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	// Probe of the job
+	// ref: io.k8s.api.core.v1.Missing
+	Probe *api_v1.Probe ` + "`json:\"probe\"`" + `
+}
+`, []string{
+			`types.go:29:2: error: "io.k8s.api.core.v1.Missing" is not defined by Kedge spec or upstream schemas [unresolved-ref]`,
+		}},
+		{RuleMissingK8sComment, `
+// JobSpecMod is a job
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	api_v1.PodSpec ` + "`json:\",inline\"`" + `
+}
+`, []string{
+			`types.go:27:2: error: embedded api_v1.PodSpec has no 'k8s:' comment, so nothing is injected from it [missing-k8s-comment]`,
+		}},
+		{RuleUnusedDefinition, `
+// Volume is a volume
+// kedgeSpec: io.kedge.Volume
+type Volume struct {
+	// Name of the volume
+	Name string ` + "`json:\"name\"`" + `
+}
+`, []string{
+			`types.go:26:6: warning: definition "io.kedge.Volume" of Volume is not reachable from io.kedge.App [unused-definition]`,
+		}},
+		{"reachable through references", `
+// JobSpecMod is a job
+// kedgeSpec: io.kedge.JobSpecMod
+type JobSpecMod struct {
+	// Volumes of the job
+	// ref: io.kedge.Volume
+	Volumes map[string]Volume ` + "`json:\"volumes\"`" + `
+}
+
+// Volume is a volume
+// kedgeSpec: io.kedge.Volume
+type Volume struct {
+	// Source of the volume
+	// ref: io.kedge.Source
+	Source *Source ` + "`json:\"source\"`" + `
+}
+
+// Source is where the volume comes from
+// kedgeSpec: io.kedge.Source
+type Source struct {
+	// Path of the source
+	Path string ` + "`json:\"path\"`" + `
+}
+`, nil},
+	}
+
+	for _, test := range tests {
+		g, err := NewGenerator(GeneratorOptions{
+			Kedge:      []Source{{Name: "types.go", Content: []byte(testLintSpec + test.source)}},
+			Kubernetes: Source{Name: "swagger.json", Content: []byte(testKubernetesSchema)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the spec the problems are added to has none
+		diags, err := Lint(g, LintRules)
+		if err != nil {
+			t.Fatalf("%s: could not lint: %v", test.name, err)
+		}
+		var found []string
+		for _, d := range diags {
+			found = append(found, d.String())
+		}
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, found)
+		}
+
+		// disabled rules report nothing
+		rules, err := SelectLintRules(nil, []string{test.name})
+		if err != nil {
+			continue
+		}
+		if diags, err = Lint(g, rules); err != nil {
+			t.Fatalf("%s: could not lint: %v", test.name, err)
+		}
+		for _, d := range diags {
+			if d.Rule == test.name {
+				t.Errorf("%s: expected disabled rule to report nothing, got %v", test.name, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,652 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// Returned by LanguageServer.Serve when the client asks it to exit without
+// shutting it down first, the process should then exit with 1
+var ErrExitWithoutShutdown = errors.New("exit requested without shutdown")
+
+// Codes of JSON-RPC errors
+const (
+	lspParseError     = -32700
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+)
+
+// Kinds of LSP values that are used
+const (
+	lspSyncFull          = 1
+	lspSeverityError     = 1
+	lspCompletionField   = 5
+	lspCompletionKeyword = 14
+	lspCompletionEnum    = 20
+)
+
+// LanguageServer speaks the Language Server Protocol for Kedge files. It
+// completes field names and enum values, shows descriptions of fields on
+// hover and publishes validation problems whenever a file changes. Only
+// what is needed for that is implemented, documents are always synced
+// fully.
+type LanguageServer struct {
+	root      string
+	validator *Validator
+	explainer *Explainer
+
+	// text of the open documents by their URI
+	docs map[string]string
+	w    io.Writer
+	// messages are written from one goroutine only, but writes are
+	// guarded anyway so that nothing is ever interleaved
+	mu       sync.Mutex
+	shutdown bool
+}
+
+// Returns the language server for files of the definition with the key
+// root, usually AppKey
+func NewLanguageServer(defs spec.Definitions, root string, strict bool) (*LanguageServer, error) {
+	if _, ok := defs[root]; !ok {
+		return nil, &DefinitionNotFoundError{Key: root}
+	}
+	return &LanguageServer{
+		root:      root,
+		validator: NewValidator(defs, strict),
+		explainer: NewExplainer(defs),
+		docs:      make(map[string]string),
+	}, nil
+}
+
+// lspMessage is a JSON-RPC request, response or notification
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lspPosition is a position in a document, characters are counted in UTF-16
+// code units as LSP does
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	Position       lspPosition     `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspMarkup struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspCompletionItem struct {
+	Label         string     `json:"label"`
+	Kind          int        `json:"kind"`
+	Detail        string     `json:"detail,omitempty"`
+	Documentation *lspMarkup `json:"documentation,omitempty"`
+	InsertText    string     `json:"insertText,omitempty"`
+}
+
+// Reads messages from r and writes responses and notifications to w until
+// the client asks to exit or r ends
+func (s *LanguageServer) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	in := bufio.NewReader(r)
+	for {
+		content, err := readLSPMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg lspMessage
+		if err := json.Unmarshal(content, &msg); err != nil {
+			s.reply(nil, nil, &lspResponseError{Code: lspParseError, Message: err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		result, rerr := s.handle(msg)
+		if msg.ID != nil {
+			s.reply(msg.ID, result, rerr)
+		}
+	}
+}
+
+// Handles a single request or notification and returns the result
+func (s *LanguageServer) handle(msg lspMessage) (interface{}, *lspResponseError) {
+	var params lspDocumentParams
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspResponseError{Code: lspInvalidParams, Message: err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": lspSyncFull,
+				"hoverProvider":    true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{":", " ", "-"},
+				},
+			},
+			"serverInfo": map[string]string{"name": sarifToolName, "version": Version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = params.TextDocument.Text
+		s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		// the whole text is sent on every change
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uri] = params.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         uri,
+			"diagnostics": []lspDiagnostic{},
+		})
+	case "textDocument/completion":
+		return map[string]interface{}{
+			"isIncomplete": false,
+			"items":        s.complete(s.docs[uri], params.Position),
+		}, nil
+	case "textDocument/hover":
+		value := s.hover(s.docs[uri], params.Position)
+		if value == "" {
+			return nil, nil
+		}
+		return map[string]interface{}{
+			"contents": lspMarkup{Kind: "markdown", Value: value},
+		}, nil
+	default:
+		if msg.ID != nil {
+			return nil, &lspResponseError{Code: lspMethodNotFound, Message: fmt.Sprintf("method %q is not supported", msg.Method)}
+		}
+	}
+	return nil, nil
+}
+
+// Line numbers of YAML errors are only found in their messages
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+// Validates the document and publishes the problems found
+func (s *LanguageServer) publishDiagnostics(uri string) {
+	text := s.docs[uri]
+	lines := strings.Split(text, "\n")
+	diags := []lspDiagnostic{}
+
+	errs, err := s.validator.ValidateReader(s.root, uri, strings.NewReader(text))
+	if err != nil {
+		line := 0
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			line--
+		}
+		msg := errors.Cause(err).Error()
+		diags = append(diags, lspDiagnostic{
+			Range:    lineRange(lines, line, 0),
+			Severity: lspSeverityError,
+			Source:   sarifToolName,
+			Message:  msg,
+		})
+	}
+	for _, e := range errs {
+		// columns of YAML are counted in characters
+		character := 0
+		if e.Line > 0 && e.Line <= len(lines) {
+			line := []rune(lines[e.Line-1])
+			if e.Column-1 < len(line) {
+				line = line[:e.Column-1]
+			}
+			character = utf16Length(string(line))
+		}
+		diags = append(diags, lspDiagnostic{
+			Range:    lineRange(lines, e.Line-1, character),
+			Severity: lspSeverityError,
+			Source:   sarifToolName,
+			Message:  e.Path + ": " + e.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+// Returns the range from the position to the end of its line
+func lineRange(lines []string, line, character int) lspRange {
+	if line < 0 {
+		line = 0
+	}
+	end := character
+	if line < len(lines) && utf16Length(lines[line]) > end {
+		end = utf16Length(lines[line])
+	}
+	return lspRange{
+		Start: lspPosition{Line: line, Character: character},
+		End:   lspPosition{Line: line, Character: end},
+	}
+}
+
+// Returns the completion items at the position, the names of the fields
+// not given yet when a field name is being written, else the values of
+// the field if they are known
+func (s *LanguageServer) complete(text string, pos lspPosition) []lspCompletionItem {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return []lspCompletionItem{}
+	}
+	before := lines[pos.Line]
+	before = before[:utf16Offset(before, pos.Character)]
+	current := parseYAMLLine(before)
+	path := yamlPath(lines, pos.Line, current)
+	items := []lspCompletionItem{}
+
+	if current.key != "" {
+		// the value of the field is being written
+		schema, err := s.schemaAt(append(path, current.key))
+		if err != nil {
+			return items
+		}
+		schema, err = s.explainer.deref(schema)
+		if err != nil {
+			return items
+		}
+		for _, v := range schema.Enum {
+			label, ok := v.(string)
+			if !ok {
+				b, _ := json.Marshal(v)
+				label = string(b)
+			}
+			items = append(items, lspCompletionItem{Label: label, Kind: lspCompletionEnum})
+		}
+		if len(schema.Enum) == 0 && typeMatches(schema, "boolean") {
+			for _, v := range []string{"true", "false"} {
+				items = append(items, lspCompletionItem{Label: v, Kind: lspCompletionKeyword})
+			}
+		}
+		return items
+	}
+
+	schema, err := s.schemaAt(path)
+	if err != nil {
+		return items
+	}
+	fields, err := s.explainer.childFields(schema)
+	if err != nil {
+		return items
+	}
+	given := siblingKeys(lines, pos.Line, current.indent)
+	for _, f := range fields {
+		if given[f.Name] {
+			continue
+		}
+		item := lspCompletionItem{
+			Label:      f.Name,
+			Kind:       lspCompletionField,
+			Detail:     f.Type + requiredMark(f.Required),
+			InsertText: f.Name + ": ",
+		}
+		if f.Description != "" {
+			item.Documentation = &lspMarkup{Kind: "markdown", Value: f.Description}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Returns the description of the field whose name is at the position
+func (s *LanguageServer) hover(text string, pos lspPosition) string {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return ""
+	}
+	current := parseYAMLLine(lines[pos.Line])
+	if current.key == "" {
+		return ""
+	}
+	path := append(yamlPath(lines, pos.Line, current), current.key)
+	schema, err := s.schemaAt(path)
+	if err != nil {
+		return ""
+	}
+	t, err := s.explainer.typeName(schema)
+	if err != nil {
+		return ""
+	}
+	desc := schema.Description
+	if desc == "" {
+		if d, err := s.explainer.deref(schema); err == nil {
+			desc = d.Description
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "**%s** `%s`", current.key, t)
+	if s.requiredAt(path) {
+		b.WriteString(" (required)")
+	}
+	if desc != "" {
+		fmt.Fprintf(&b, "\n\n%s", desc)
+	}
+	return b.String()
+}
+
+// Returns the schema of the value at the path from the root definition,
+// '[]' in the path steps into arrays and unknown names step into the values
+// of maps
+func (s *LanguageServer) schemaAt(path []string) (spec.Schema, error) {
+	schema := refSchema(s.root)
+	for _, name := range path {
+		if name == "[]" {
+			continue
+		}
+		fields, _, err := s.explainer.fields(schema)
+		if err != nil {
+			return schema, err
+		}
+		if f, ok := fields[name]; ok {
+			schema = f
+			continue
+		}
+		obj, err := s.explainer.elem(schema)
+		if err != nil {
+			return schema, err
+		}
+		if obj.AdditionalProperties == nil || obj.AdditionalProperties.Schema == nil {
+			return schema, fmt.Errorf("field %q not found", name)
+		}
+		schema = *obj.AdditionalProperties.Schema
+	}
+	return schema, nil
+}
+
+// Returns true if the last field of the path is required
+func (s *LanguageServer) requiredAt(path []string) bool {
+	parent, err := s.schemaAt(path[:len(path)-1])
+	if err != nil {
+		return false
+	}
+	_, required, err := s.explainer.fields(parent)
+	return err == nil && required[path[len(path)-1]]
+}
+
+// yamlLine is a single line of YAML, as much as is needed to find out where
+// in the document it is. YAML being edited is mostly invalid, so lines are
+// looked at on their own instead of parsing the whole document.
+type yamlLine struct {
+	// columns of the '- ' markers of sequence items the line starts with
+	dashes []int
+	// column the content starts at after spaces and dashes
+	indent int
+	key    string
+	value  string
+	// blank and comment only lines
+	blank bool
+	// '---' that separates documents
+	separator bool
+}
+
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"'#:][^:#]*?)\s*:(\s|$)`)
+
+func parseYAMLLine(text string) yamlLine {
+	var l yamlLine
+	rest := strings.TrimLeft(text, " ")
+	l.indent = len(text) - len(rest)
+	for rest == "-" || strings.HasPrefix(rest, "- ") {
+		l.dashes = append(l.dashes, l.indent)
+		trimmed := strings.TrimLeft(rest[1:], " ")
+		l.indent += len(rest) - len(trimmed)
+		rest = trimmed
+	}
+	if l.indent == 0 && strings.HasPrefix(rest, "---") {
+		l.separator = true
+		return l
+	}
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		l.blank = len(l.dashes) == 0
+		return l
+	}
+	// a line being written ends with ':' without the space after it yet
+	m := yamlKey.FindStringSubmatch(rest + " ")
+	if m == nil {
+		return l
+	}
+	l.key = strings.Trim(m[1], `"'`)
+	value := ""
+	if len(m[0]) < len(rest) {
+		value = strings.TrimSpace(rest[len(m[0]):])
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	l.value = value
+	return l
+}
+
+// Returns the path of the mapping the content of the given line is in,
+// field names with '[]' for items of sequences, found by walking up from
+// the line and collecting the fields that are indented less
+func yamlPath(lines []string, line int, current yamlLine) []string {
+	var path []string
+	column := current.indent
+	// the field owning a sequence can be at the same column as its items
+	sameColumn := false
+	dashes := func(l yamlLine) {
+		for i := len(l.dashes) - 1; i >= 0; i-- {
+			if d := l.dashes[i]; d < column {
+				path = append(path, "[]")
+				column, sameColumn = d, true
+			}
+		}
+	}
+	dashes(current)
+
+	for j := line - 1; j >= 0 && (column > 0 || sameColumn); j-- {
+		l := parseYAMLLine(lines[j])
+		if l.separator {
+			break
+		}
+		if l.blank {
+			continue
+		}
+		if l.key != "" && (l.indent < column || sameColumn && l.indent == column) {
+			path = append(path, l.key)
+			column, sameColumn = l.indent, false
+		}
+		dashes(l)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Returns the keys already given in the mapping the line is in, they are
+// the keys at the same column up and down to where the mapping ends
+func siblingKeys(lines []string, line, column int) map[string]bool {
+	keys := make(map[string]bool)
+	for _, step := range []int{-1, 1} {
+		for j := line + step; j >= 0 && j < len(lines); j += step {
+			l := parseYAMLLine(lines[j])
+			if l.separator {
+				break
+			}
+			if l.blank || l.indent > column {
+				continue
+			}
+			if l.indent < column || len(l.dashes) > 0 {
+				// a new item starts a new mapping, but the item of the
+				// line itself is in the mapping when walking up
+				if step < 0 && l.indent == column && l.key != "" {
+					keys[l.key] = true
+				}
+				break
+			}
+			if l.key != "" {
+				keys[l.key] = true
+			}
+		}
+	}
+	return keys
+}
+
+// Returns the length of the text in UTF-16 code units
+func utf16Length(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16RuneLength(r)
+	}
+	return n
+}
+
+// Returns the byte offset in the text of the character given in UTF-16 code
+// units, the length of the text if it is shorter
+func utf16Offset(text string, character int) int {
+	n := 0
+	for i, r := range text {
+		if n >= character {
+			return i
+		}
+		n += utf16RuneLength(r)
+	}
+	return len(text)
+}
+
+// Characters outside of the Basic Multilingual Plane take two code units
+func utf16RuneLength(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// Reads a single message with its header from r
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" {
+				return nil, io.EOF
+			}
+			return nil, errors.Wrap(err, "could not read message header")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i > 0 && strings.EqualFold(line[:i], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[i+1:])); err != nil {
+				return nil, errors.Wrapf(err, "invalid header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message has no Content-Length header")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, errors.Wrap(err, "could not read message")
+	}
+	return content, nil
+}
+
+// Writes the response to the request with the given id
+func (s *LanguageServer) reply(id *json.RawMessage, result interface{}, rerr *lspResponseError) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rerr != nil {
+		msg["error"] = rerr
+	} else {
+		msg["result"] = result
+	}
+	s.write(msg)
+}
+
+// Writes the notification
+func (s *LanguageServer) notify(method string, params interface{}) {
+	s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *LanguageServer) write(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("could not marshal message: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		log.Errorf("could not write message: %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected yamlLine
+	}{
+		{"field", "name: web", yamlLine{key: "name", value: "web"}},
+		{"indented field", "    image: nginx", yamlLine{indent: 4, key: "image", value: "nginx"}},
+		{"field being written", "  replicas:", yamlLine{indent: 2, key: "replicas"}},
+		{"trailing comment", "replicas: 2 # two", yamlLine{key: "replicas", value: "2"}},
+		{"quoted key", `"app name": web`, yamlLine{key: "app name", value: "web"}},
+		{"url value", "url: http://example.com", yamlLine{key: "url", value: "http://example.com"}},
+		{"item", "- name: web", yamlLine{dashes: []int{0}, indent: 2, key: "name", value: "web"}},
+		{"nested items", "  - - 80", yamlLine{dashes: []int{2, 4}, indent: 6}},
+		{"empty item", "-", yamlLine{dashes: []int{0}, indent: 1}},
+		{"partial key", "  na", yamlLine{indent: 2}},
+		{"blank", "   ", yamlLine{indent: 3, blank: true}},
+		{"comment", "  # containers:", yamlLine{indent: 2, blank: true}},
+		{"separator", "---", yamlLine{separator: true}},
+	}
+
+	for _, test := range tests {
+		if l := parseYAMLLine(test.text); !reflect.DeepEqual(l, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, l)
+		}
+	}
+}
+
+func TestYAMLPath(t *testing.T) {
+	doc := strings.Split(`name: web
+containers:
+- name: nginx
+  ports:
+  - containerPort: 80
+
+    protocol: TCP
+  env:
+    - name: A
+labels:
+  app: web
+---
+services:
+- name: web`, "\n")
+
+	tests := []struct {
+		name     string
+		line     int
+		expected []string
+	}{
+		{"top level", 0, nil},
+		{"field of item", 2, []string{"containers", "[]"}},
+		{"sibling of item field", 3, []string{"containers", "[]"}},
+		{"item of nested sequence", 4, []string{"containers", "[]", "ports", "[]"}},
+		{"after blank line", 6, []string{"containers", "[]", "ports", "[]"}},
+		{"indented sequence", 8, []string{"containers", "[]", "env", "[]"}},
+		{"map", 10, []string{"labels"}},
+		{"next document", 13, []string{"services", "[]"}},
+	}
+
+	for _, test := range tests {
+		path := yamlPath(doc, test.line, parseYAMLLine(doc[test.line]))
+		if !reflect.DeepEqual(path, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, path)
+		}
+	}
+}
+
+func TestUTF16Offset(t *testing.T) {
+	tests := []struct {
+		text      string
+		character int
+		expected  int
+	}{
+		{"name: web", 4, 4},
+		{"name: web", 20, 9},
+		// 'é' is two bytes and one code unit
+		{"café: x", 5, 6},
+		// '😀' is four bytes and two code units
+		{"a😀: x", 3, 5},
+		{"a😀: x", 4, 6},
+	}
+
+	for _, test := range tests {
+		if offset := utf16Offset(test.text, test.character); offset != test.expected {
+			t.Errorf("%q at %d: expected byte offset %d, got %d", test.text, test.character, test.expected, offset)
+		}
+	}
+	if n := utf16Length("a😀é"); n != 4 {
+		t.Errorf("expected 4 code units, got %d", n)
+	}
+}
+
+func TestLanguageServer(t *testing.T) {
+	defs := testDefinitions(t, `{
+		"io.kedge.App": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "description": "Name of the app"},
+				"controller": {"type": "string", "enum": ["deployment", "job"]},
+				"replicas": {"type": "integer"},
+				"containers": {"type": "array", "items": {"$ref": "#/definitions/io.kedge.Container"}}
+			}
+		},
+		"io.kedge.Container": {
+			"type": "object",
+			"properties": {
+				"image": {"type": "string", "description": "Docker image name"},
+				"command": {"type": "string"}
+			}
+		}
+	}`)
+	s, err := NewLanguageServer(defs, "io.kedge.App", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri := "file:///app.yaml"
+	text := "name: \"😀\"\nreplicas: 😀\ncontroller: \ncontainers:\n- image: nginx\n  "
+	var in bytes.Buffer
+	for i, msg := range []map[string]interface{}{
+		{"id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "text": text},
+		}},
+		{"id": 2, "method": "textDocument/completion", "params": lspTestPosition(uri, 2, 12)},
+		{"id": 3, "method": "textDocument/completion", "params": lspTestPosition(uri, 5, 2)},
+		{"id": 4, "method": "textDocument/hover", "params": lspTestPosition(uri, 4, 4)},
+		{"id": 5, "method": "textDocument/hover", "params": lspTestPosition(uri, 0, 2)},
+		{"id": 6, "method": "shutdown"},
+		{"method": "exit"},
+	} {
+		msg["jsonrpc"] = "2.0"
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+
+	var out bytes.Buffer
+	if err := s.Serve(&in, &out); err != nil {
+		t.Fatalf("expected exit after shutdown, got %v", err)
+	}
+
+	// responses by their id, and the published diagnostics
+	responses := make(map[int]json.RawMessage)
+	var diagnostics []lspDiagnostic
+	r := bufio.NewReader(&out)
+	for {
+		content, err := readLSPMessage(r)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Params struct {
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatalf("could not parse %s: %v", content, err)
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			diagnostics = msg.Params.Diagnostics
+			continue
+		}
+		responses[msg.ID] = msg.Result
+	}
+
+	var initialized struct {
+		Capabilities struct {
+			TextDocumentSync int  `json:"textDocumentSync"`
+			HoverProvider    bool `json:"hoverProvider"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(responses[1], &initialized); err != nil {
+		t.Fatalf("could not parse initialize result %s: %v", responses[1], err)
+	}
+	if initialized.Capabilities.TextDocumentSync != lspSyncFull || !initialized.Capabilities.HoverProvider {
+		t.Errorf("expected full sync and hover, got %s", responses[1])
+	}
+
+	// the emoji takes two code units
+	expected := []lspDiagnostic{
+		{
+			Range:    lspRange{Start: lspPosition{Line: 1, Character: 10}, End: lspPosition{Line: 1, Character: 12}},
+			Severity: lspSeverityError,
+			Source:   sarifToolName,
+			Message:  "$.replicas: expected integer, got string",
+		},
+		{
+			Range:    lspRange{Start: lspPosition{Line: 2, Character: 11}, End: lspPosition{Line: 2, Character: 12}},
+			Severity: lspSeverityError,
+			Source:   sarifToolName,
+			Message:  "$.controller: expected string, got null",
+		},
+	}
+	if !reflect.DeepEqual(diagnostics, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diagnostics)
+	}
+
+	for _, test := range []struct {
+		name   string
+		id     int
+		labels []string
+	}{
+		{"enum values", 2, []string{"deployment", "job"}},
+		{"fields not given yet", 3, []string{"command"}},
+	} {
+		var completion struct {
+			Items []lspCompletionItem `json:"items"`
+		}
+		if err := json.Unmarshal(responses[test.id], &completion); err != nil {
+			t.Fatalf("%s: could not parse %s: %v", test.name, responses[test.id], err)
+		}
+		var labels []string
+		for _, item := range completion.Items {
+			labels = append(labels, item.Label)
+		}
+		if !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.labels, labels)
+		}
+	}
+
+	for _, test := range []struct {
+		name  string
+		id    int
+		value string
+	}{
+		{"field of item", 4, "**image** `string`\n\nDocker image name"},
+		{"required field", 5, "**name** `string` (required)\n\nName of the app"},
+	} {
+		var hover struct {
+			Contents lspMarkup `json:"contents"`
+		}
+		if err := json.Unmarshal(responses[test.id], &hover); err != nil {
+			t.Fatalf("%s: could not parse %s: %v", test.name, responses[test.id], err)
+		}
+		if hover.Contents.Value != test.value {
+			t.Errorf("%s: expected %q, got %q", test.name, test.value, hover.Contents.Value)
+		}
+	}
+}
+
+// Returns the params of a request at the position of the document
+func lspTestPosition(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+// Name of the file that lists all the versions generated in the releases
+// directory
+const MatrixIndexFile = "index.json"
+
+// Name of the generated OpenAPI schema file in every version's directory
+const MatrixOpenAPIFile = "openapi.json"
+
+// Release is a Kubernetes release and the location of its OpenAPI schema
+type Release struct {
+	// Version label of the release e.g. 'v1.7', also used as the name of
+	// the directory to which the schema for this release is written
+	Version string `yaml:"version"`
+	// Location of Kubernetes OpenAPI schema file for this release
+	Schema string `yaml:"schema"`
+}
+
+// Parses release given in the form '<version>=<schema file>'
+// e.g. 'v1.7=swagger-1.7.json'
+func ParseRelease(s string) (Release, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Release{}, fmt.Errorf("release %q is not of the form <version>=<schema file>", s)
+	}
+	version := parts[0]
+	if strings.ContainsAny(version, `/\`) || version == "." || version == ".." {
+		return Release{}, fmt.Errorf("version %q can't be used as a directory name", version)
+	}
+	return Release{Version: version, Schema: parts[1]}, nil
+}
+
+// MatrixIndex lists all the versions for which schema was generated
+type MatrixIndex struct {
+	Versions []MatrixVersion `json:"versions"`
+}
+
+// MatrixVersion is a single generated version in the MatrixIndex
+type MatrixVersion struct {
+	// Version label of the Kubernetes release
+	Version string `json:"version"`
+	// Location of Kubernetes OpenAPI schema that was used as input
+	KubernetesSchema string `json:"kubernetesSchema"`
+	// Path of generated OpenAPI schema relative to the releases directory
+	OpenAPI string `json:"openapi"`
+}
+
+// Generates OpenAPI schema for Kedge for every Kubernetes release in config
+// and writes each of them in its own directory named after the version inside
+// the releases directory, e.g. 'v1.7/openapi.json', also writes an index of
+// them.
+// Files written in YAML have the '.yaml' extension instead.
+// When only checking, DriftError is returned with all the files not up to date.
+func GenerateMatrix(cfg *Config) error {
+	releases := cfg.Upstream.Releases
+	if len(releases) == 0 {
+		return fmt.Errorf("no Kubernetes releases given")
+	}
+
+	index := MatrixIndex{}
+	drift := &DriftError{}
+	format := cfg.Output.Format
+	openapiFile := withExtension(MatrixOpenAPIFile, format)
+	seen := make(map[string]bool)
+	for _, r := range releases {
+		if seen[r.Version] {
+			return fmt.Errorf("version %q given more than once", r.Version)
+		}
+		seen[r.Version] = true
+
+		log.Debugf("generating schema for %s from %q", r.Version, r.Schema)
+		c := *cfg
+		c.Upstream.Kubernetes = r.Schema
+		api, err := GenerateOpenAPI(&c)
+		if err != nil {
+			return errors.Wrapf(err, "version %s", r.Version)
+		}
+
+		dir := filepath.Join(cfg.Output.ReleasesDir, r.Version)
+		if !cfg.Output.Check {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return errors.Wrapf(err, "could not create directory %q", dir)
+			}
+		}
+		if err := writeOutput(filepath.Join(dir, openapiFile), api, format, cfg.Output.Check, drift); err != nil {
+			return err
+		}
+
+		index.Versions = append(index.Versions, MatrixVersion{
+			Version:          r.Version,
+			KubernetesSchema: r.Schema,
+			OpenAPI:          path.Join(r.Version, openapiFile),
+		})
+	}
+	indexFile := withExtension(MatrixIndexFile, format)
+	if err := writeOutput(filepath.Join(cfg.Output.ReleasesDir, indexFile), index, format, cfg.Output.Check, drift); err != nil {
+		return err
+	}
+	if len(drift.Files) > 0 {
+		return drift
+	}
+	return nil
+}
+
+// Returns the file name with its extension replaced by the one of format
+func withExtension(name, format string) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + FormatExtension(format)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+func TestGenerateMatrix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-matrix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := testConfig(t, dir)
+
+	newer := testNewerKubernetesSchema(t, dir)
+	cfg.Upstream.Releases = []Release{
+		{Version: "v1.7", Schema: cfg.Upstream.Kubernetes},
+		{Version: "v1.8", Schema: newer},
+	}
+	cfg.Output.ReleasesDir = filepath.Join(dir, "schemas")
+	if err := GenerateMatrix(cfg); err != nil {
+		t.Fatalf("could not generate: %v", err)
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(cfg.Output.ReleasesDir, MatrixIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := json.Marshal(MatrixIndex{Versions: []MatrixVersion{
+		{Version: "v1.7", KubernetesSchema: cfg.Upstream.Kubernetes, OpenAPI: "v1.7/openapi.json"},
+		{Version: "v1.8", KubernetesSchema: newer, OpenAPI: "v1.8/openapi.json"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, "index", string(expected), json.RawMessage(index))
+
+	for _, test := range []struct {
+		version    string
+		workingDir bool
+	}{
+		{"v1.7", false},
+		{"v1.8", true},
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(cfg.Output.ReleasesDir, test.version, MatrixOpenAPIFile))
+		if err != nil {
+			t.Fatalf("%s: %v", test.version, err)
+		}
+		var api spec.Swagger
+		if err := json.Unmarshal(content, &api); err != nil {
+			t.Fatalf("%s: %v", test.version, err)
+		}
+		if _, ok := api.Definitions[AppKey]; !ok {
+			t.Errorf("%s: expected the root definition to be generated", test.version)
+		}
+		_, ok := api.Definitions["io.kedge.ContainerSpec"].Properties["workingDir"]
+		if ok != test.workingDir {
+			t.Errorf("%s: expected container to have workingDir to be %v", test.version, test.workingDir)
+		}
+	}
+
+	// nothing changed since
+	cfg.Output.Check = true
+	if err := GenerateMatrix(cfg); err != nil {
+		t.Errorf("expected the releases to be up to date, got %v", err)
+	}
+
+	cfg.Upstream.Releases = append(cfg.Upstream.Releases, Release{Version: "v1.7", Schema: newer})
+	if err := GenerateMatrix(cfg); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("expected version given twice to be an error, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Returns v as indented JSON with the keys of every object in sorted order
+// and a trailing newline, so that the same value is always written byte for
+// byte the same. Types like spec.Schema write their keys in their own order,
+// so the JSON is decoded again and written from plain maps, numbers are kept
+// exactly as they were written.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	tree, err := jsonTree(v)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal JSON")
+	}
+	return append(out, '\n'), nil
+}
+
+// Formats generated output can be written in
+const (
+	// Indented JSON with sorted keys
+	FormatJSON = "json"
+	// JSON on a single line with sorted keys
+	FormatJSONCompact = "json-compact"
+	// YAML with sorted keys
+	FormatYAML = "yaml"
+)
+
+// All the formats output can be written in
+var OutputFormats = []string{FormatJSON, FormatJSONCompact, FormatYAML}
+
+// Returns an error if output can't be written in format
+func CheckFormat(format string) error {
+	for _, f := range OutputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, must be one of: %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// Returns the extension of the files written in format, including the dot
+func FormatExtension(format string) string {
+	if format == FormatYAML {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+// Returns v in the given format, the output is the same byte for byte for
+// the same value in every format, just like with CanonicalJSON
+func Marshal(v interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return CanonicalJSON(v)
+	case FormatJSONCompact:
+		tree, err := jsonTree(v)
+		if err != nil {
+			return nil, err
+		}
+		out, err := json.Marshal(tree)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal JSON")
+		}
+		return append(out, '\n'), nil
+	case FormatYAML:
+		tree, err := jsonTree(v)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(yamlNode(tree)); err != nil {
+			return nil, errors.Wrap(err, "could not marshal YAML")
+		}
+		if err := enc.Close(); err != nil {
+			return nil, errors.Wrap(err, "could not marshal YAML")
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, CheckFormat(format)
+}
+
+// Writes v in the given format to w
+func Write(w io.Writer, v interface{}, format string) error {
+	b, err := Marshal(v, format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// Writes v in the given format to the given file
+func WriteFile(filename string, v interface{}, format string) error {
+	b, err := Marshal(v, format)
+	if err != nil {
+		return errors.Wrapf(err, "%q", filename)
+	}
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		return errors.Wrapf(err, "could not write file %q", filename)
+	}
+	return nil
+}
+
+// Returns true if the file has exactly what WriteFile would write for v,
+// a file that does not exist is not up to date
+func CheckFile(filename string, v interface{}, format string) (bool, error) {
+	b, err := Marshal(v, format)
+	if err != nil {
+		return false, errors.Wrapf(err, "%q", filename)
+	}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "could not read file %q", filename)
+	}
+	return bytes.Equal(content, b), nil
+}
+
+// Writes v as canonical JSON to w
+func WriteJSON(w io.Writer, v interface{}) error {
+	return Write(w, v, FormatJSON)
+}
+
+// Writes v as canonical JSON to the given file
+func WriteJSONFile(filename string, v interface{}) error {
+	return WriteFile(filename, v, FormatJSON)
+}
+
+// Returns true if the file has exactly what WriteJSONFile would write for v,
+// a file that does not exist is not up to date
+func CheckJSONFile(filename string, v interface{}) (bool, error) {
+	return CheckFile(filename, v, FormatJSON)
+}
+
+// Writes v to the output file, or if only checking then records the file
+// in drift when it is not up to date
+func writeOutput(filename string, v interface{}, format string, check bool, drift *DriftError) error {
+	if !check {
+		return WriteFile(filename, v, format)
+	}
+	ok, err := CheckFile(filename, v, format)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		drift.Files = append(drift.Files, filename)
+	}
+	return nil
+}
+
+// Returns v as it is written in JSON, objects are maps and numbers are
+// json.Number so that they are kept exactly as they were written
+func jsonTree(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal JSON")
+	}
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
+		return nil, errors.Wrap(err, "could not decode marshalled JSON")
+	}
+	return tree, nil
+}
+
+// Returns the YAML node for the value of jsonTree, keys of mappings are
+// sorted and every scalar is tagged with its JSON type, so that strings
+// that look like numbers or booleans are quoted and the YAML means exactly
+// the same as the JSON
+func yamlNode(v interface{}) *yaml.Node {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.Content = append(n.Content, yamlScalar("!!str", k), yamlNode(t[k]))
+		}
+		if len(keys) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, e := range t {
+			n.Content = append(n.Content, yamlNode(e))
+		}
+		if len(t) == 0 {
+			n.Style = yaml.FlowStyle
+		}
+		return n
+	case string:
+		n := yamlScalar("!!str", t)
+		if strings.Contains(t, "\n") {
+			n.Style = yaml.LiteralStyle
+		}
+		return n
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return yamlScalar("!!int", t.String())
+		}
+		// YAML 1.1 parsers only read exponents of numbers with a dot
+		f := t.String()
+		if i := strings.IndexAny(f, "eE"); i >= 0 && !strings.Contains(f[:i], ".") {
+			f = f[:i] + ".0" + f[i:]
+		}
+		return yamlScalar("!!float", f)
+	case bool:
+		return yamlScalar("!!bool", fmt.Sprint(t))
+	}
+	return yamlScalar("!!null", "null")
+}
+
+func yamlScalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Returns the content of every file in dir by their names
+func readDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string][]byte)
+	for _, f := range files {
+		if contents[f.Name()], err = ioutil.ReadFile(filepath.Join(dir, f.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contents
+}
+
+func TestConversionDeterministic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := testConfig(t, dir)
+
+	for _, format := range []string{FormatJSON, FormatJSONCompact, FormatYAML} {
+		// maps are iterated in random order, a few runs would tell
+		var first map[string][]byte
+		for i := 0; i < 5; i++ {
+			out := filepath.Join(dir, format, strings.Repeat("x", i+1))
+			cfg.Output = OutputConfig{
+				Format: format,
+				File:   filepath.Join(out, "schema"),
+				OutDir: filepath.Join(out, "definitions"),
+				Bundle: true,
+			}
+			if err := os.MkdirAll(out, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := Conversion(cfg); err != nil {
+				t.Fatalf("%s: could not generate: %v", format, err)
+			}
+			files := readDir(t, cfg.Output.OutDir)
+			if files["schema"], err = ioutil.ReadFile(cfg.Output.File); err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = files
+				continue
+			}
+			for name, content := range files {
+				if !bytes.Equal(content, first[name]) {
+					t.Errorf("%s: %s of run %d is not the same as of the first run", format, name, i+1)
+				}
+			}
+			if len(files) != len(first) {
+				t.Errorf("%s: run %d wrote %d files, the first run %d", format, i+1, len(files), len(first))
+			}
+		}
+	}
+}
+
+func TestConversionCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := testConfig(t, dir)
+	cfg.Output.File = filepath.Join(dir, "schema.json")
+	cfg.Output.OutDir = filepath.Join(dir, "definitions")
+	if err := Conversion(cfg); err != nil {
+		t.Fatalf("could not generate: %v", err)
+	}
+	written := readDir(t, cfg.Output.OutDir)
+
+	cfg.Output.Check = true
+	if err := Conversion(cfg); err != nil {
+		t.Errorf("expected unchanged output to be up to date, got %v", err)
+	}
+
+	changed := strings.Replace(testKedgeSpec, "// Name of app", "// Name of the app", -1)
+	if err := ioutil.WriteFile(cfg.Kedge.Sources[0], []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = Conversion(cfg)
+	drift, ok := err.(*DriftError)
+	if !ok {
+		t.Fatalf("expected DriftError, got %v", err)
+	}
+	sort.Strings(drift.Files)
+	expected := []string{
+		filepath.Join(cfg.Output.OutDir, "deploymentspecmod.json"),
+		filepath.Join(cfg.Output.OutDir, "jobspecmod.json"),
+		cfg.Output.File,
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(drift.Files, expected) {
+		t.Errorf("expected files %v not to be up to date, got %v", expected, drift.Files)
+	}
+	if files := readDir(t, cfg.Output.OutDir); !reflect.DeepEqual(files, written) {
+		t.Error("expected checking not to write anything")
+	}
+}
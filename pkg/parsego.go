@@ -23,6 +23,9 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -33,45 +36,76 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Comments starting with this have JSON object with the keywords of the
+// schema that Go types can't express, e.g.
+// '+schema: {"enum": ["Always", "Never"]}'. The keywords are set on the
+// schema made from the Go type, keywords set to null are removed from it.
+const SchemaMarker = "+schema:"
+
+// Comment that says a field is required, which fields with comments are
+// anyway unless they are marked '+optional'
+const RequiredMarker = "+required"
+
 type Injection struct {
 	Target string
 	Source string
+	// Position of the embedded field the injection comes from
+	File   string
+	Line   int
+	Column int
 }
 
 // given a golang filename this function will parse the file and generate open api definition
 func GenerateOpenAPIDefinitions(filename string) (spec.Definitions, []Injection, error) {
+	return GenerateOpenAPIDefinitionsFromSources([]string{filename})
+}
+
+// given golang files or directories of a package this function will parse all
+// the files and generate open api definitions, the files are treated as one
+// package so structs can embed structs that are defined in other files
+func GenerateOpenAPIDefinitionsFromSources(sources []string) (spec.Definitions, []Injection, error) {
+	var srcs []Source
+	for _, s := range sources {
+		srcs = append(srcs, FileSource(s))
+	}
+	return ParseKedgeSources(srcs)
+}
+
+// Same as GenerateOpenAPIDefinitionsFromSources but the sources can also be
+// go source code that is already in memory
+func ParseKedgeSources(sources []Source) (spec.Definitions, []Injection, error) {
 	// this has all the definitions which will be parsed from file
 	defs := spec.Definitions(make(map[string]spec.Schema))
 	// this stores all the mapping of what object fields to inject into what
 	var mapping []Injection
 
-	fset := token.NewFileSet() // positions are relative to fset
-	// Parse the file also parse comments and add them to AST
-	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	fset, nodes, err := parseKedgeFiles(sources)
 	if err != nil {
-		return nil, mapping, errors.Wrapf(err, "could not read the go source code")
+		return nil, mapping, err
 	}
 
-	// iterate over all top-level declarations
-	for _, decl := range node.Decls {
-		// extract as generic declaration node
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok {
-			continue
-		}
-		// iterate over all the specifications
-		for _, s := range genDecl.Specs {
-			// if there is a struct type it will be stored in strct
-			strct, ok := TypeSpecToStruct(s)
+	for _, node := range nodes {
+		// iterate over all top-level declarations
+		for _, decl := range node.Decls {
+			// extract as generic declaration node
+			genDecl, ok := decl.(*ast.GenDecl)
 			if !ok {
 				continue
 			}
-			// function to parse struct
-			m, err := ParseStruct(strct, genDecl, defs, fset)
-			if err != nil {
-				return nil, mapping, errors.Wrapf(err, "could not parse struct")
+			// iterate over all the specifications
+			for _, s := range genDecl.Specs {
+				// if there is a struct type it will be stored in strct
+				strct, ok := TypeSpecToStruct(s)
+				if !ok {
+					continue
+				}
+				// function to parse struct
+				m, err := ParseStruct(strct, genDecl, defs, fset)
+				if err != nil {
+					return nil, mapping, err
+				}
+				mapping = append(mapping, m...)
 			}
-			mapping = append(mapping, m...)
 		}
 	}
 
@@ -84,6 +118,89 @@ func GenerateOpenAPIDefinitions(filename string) (spec.Definitions, []Injection,
 	return defs, mapping, nil
 }
 
+// Parses all the Kedge sources as files of one package, so that identifiers
+// referring to types defined in other files are resolved
+func parseKedgeFiles(sources []Source) (*token.FileSet, []*ast.File, error) {
+	sources, err := expandKedgeSources(sources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet() // positions are relative to fset
+	files := make(map[string]*ast.File)
+	var nodes []*ast.File
+	for _, src := range sources {
+		content, err := src.Read()
+		if err != nil {
+			return nil, nil, &SourceError{Kind: UpstreamKedge, Name: src.Name, Err: err}
+		}
+		// Parse the file also parse comments and add them to AST
+		node, err := parser.ParseFile(fset, src.Name, content, parser.ParseComments)
+		if err != nil {
+			return nil, nil, toParseError(src.Name, err)
+		}
+		files[src.Name] = node
+		nodes = append(nodes, node)
+	}
+	// resolve the identifiers that refer to types defined in other files,
+	// the error is ignored since it only complains about builtin types
+	// and imports which are not needed here
+	ast.NewPackage(fset, files, nil, nil)
+	return fset, nodes, nil
+}
+
+// Replaces the sources that are directories on disk with the go files in them
+func expandKedgeSources(sources []Source) ([]Source, error) {
+	var expanded []Source
+	for _, src := range sources {
+		if src.Content != nil {
+			expanded = append(expanded, src)
+			continue
+		}
+		filenames, err := ExpandSources([]string{src.Name})
+		if err != nil {
+			return nil, &SourceError{Kind: UpstreamKedge, Name: src.Name, Err: err}
+		}
+		for _, f := range filenames {
+			expanded = append(expanded, FileSource(f))
+		}
+	}
+	if len(expanded) == 0 {
+		return nil, ErrNoKedgeSources
+	}
+	return expanded, nil
+}
+
+// Given list of go files or directories returns the go files, for directories
+// all the go files in it except tests are returned in sorted order
+func ExpandSources(sources []string) ([]string, error) {
+	var filenames []string
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read the go source code")
+		}
+		if !info.IsDir() {
+			filenames = append(filenames, src)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(src, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if !strings.HasSuffix(m, "_test.go") {
+				filenames = append(filenames, m)
+			}
+		}
+	}
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no go source files found in %v", sources)
+	}
+	return filenames, nil
+}
+
 // Parses a struct object and creates a definition which is added with the key
 // as specified in the comments of struct definition, also adds the keys as mentioned
 // identifies the type of the fields and converts them into as needed by openapi
@@ -111,15 +228,18 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 		log.Debug(b.String())
 
 		// get the field name from the json tag
+		if sf.Tag == nil {
+			return mapping, newParseError(fset, sf.Pos(), fmt.Errorf("no json tag found: %v", sf.Names))
+		}
 		name, err := JSONTagName(sf.Tag.Value)
 		if err != nil {
-			return mapping, errors.Wrapf(err, "name extraction from json tag error: %v", sf.Names)
+			return mapping, newParseError(fset, sf.Pos(), errors.Wrapf(err, "name extraction from json tag error: %v", sf.Names))
 		}
 
 		// Find what is the type of struct field
 		fieldtype, format, err := GetStructFieldType(sf.Type)
 		if err != nil {
-			return mapping, errors.Wrapf(err, "could not find the struct field type: %v", sf.Names)
+			return mapping, newParseError(fset, sf.Pos(), errors.Wrapf(err, "could not find the struct field type: %v", sf.Names))
 		}
 
 		// Parse comments written on top of struct field and then find the description
@@ -134,7 +254,7 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 			// and if the struct is defined locally in same package
 			// e.g.: PodSpecMod `json:",inline"`
 			identifier, ok := sf.Type.(*ast.Ident)
-			if !ok {
+			if !ok || identifier.Obj == nil {
 				continue
 			}
 			s, ok := TypeSpecToStruct(identifier.Obj.Decl)
@@ -144,8 +264,7 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 			log.Debugln("Making a recursive call")
 			m, err := ParseStruct(s, spc, defs, fset)
 			if err != nil {
-				return mapping, errors.Wrapf(err, "could not parse struct")
-				continue
+				return mapping, err
 			}
 			mapping = append(mapping, m...)
 			continue
@@ -153,7 +272,8 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 			// This is case we have embedded a type from another package
 			// so we just add it as mapping to so that we can inject the
 			// definitions from that struct to our own definition
-			s := Injection{Target: key, Source: ref}
+			p := fset.Position(sf.Pos())
+			s := Injection{Target: key, Source: ref, File: p.Filename, Line: p.Line, Column: p.Column}
 			log.Debugf("add mapping {%q: %q}", s.Target, s.Source)
 			mapping = append(mapping, s)
 			continue
@@ -163,7 +283,10 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 		// this will add necessary things
 		schema, err := CreateSchema(fieldtype, format, desc, ref)
 		if err != nil {
-			return mapping, errors.Wrapf(err, "error creating schema: %v", sf.Names)
+			return mapping, newParseError(fset, sf.Pos(), errors.Wrapf(err, "error creating schema: %v", sf.Names))
+		}
+		if schema, err = ApplySchemaMarker(schema, sf.Doc); err != nil {
+			return mapping, newParseError(fset, sf.Pos(), errors.Wrapf(err, "%v", sf.Names))
 		}
 		defs[key].Properties[name] = schema
 
@@ -174,25 +297,33 @@ func ParseStruct(strct *ast.StructType, spc *ast.GenDecl, defs spec.Definitions,
 			defs[key] = f
 		}
 	}
+
+	// keywords of the definition are set last, so that they can also
+	// change what the fields added
+	def, err := ApplySchemaMarker(defs[key], spc.Doc)
+	if err != nil {
+		return mapping, newParseError(fset, spc.Pos(), errors.Wrapf(err, "definition %q", key))
+	}
+	defs[key] = def
 	return mapping, nil
 }
 
 // Given two lists adds them, but only adds unique items
-// Duplicates are removed, using map
+// Duplicates are removed keeping the first occurrence, so the
+// order of items is always the same for the same lists
 func AddListUniqueItems(a []string, b []string) []string {
 	var merger []string
-	itemsMap := make(map[string]interface{})
+	seen := make(map[string]bool)
 	lists := [][]string{a, b}
 
 	for _, list := range lists {
 		for _, item := range list {
-			itemsMap[item] = nil
+			if !seen[item] {
+				seen[item] = true
+				merger = append(merger, item)
+			}
 		}
 	}
-
-	for k := range itemsMap {
-		merger = append(merger, k)
-	}
 	return merger
 }
 
@@ -218,9 +349,25 @@ func CreateSchema(fieldtype, format, desc, ref string) (spec.Schema, error) {
 		},
 	}
 	switch fieldtype {
-	case "integer":
+	case "integer", "number":
 		schema.Format = format
+	case "interface":
+		schema.Type = nil
 	case "object":
+		if ref != "" {
+			refObj, err := CreateJSONRef(ref)
+			if err != nil {
+				return schema, errors.Wrapf(err, "error extracting name from json tag")
+			}
+			schema.AdditionalProperties = &spec.SchemaOrBool{
+				Schema: &spec.Schema{SchemaProps: spec.SchemaProps{Ref: spec.Ref{Ref: refObj}}},
+			}
+			break
+		}
+		// other maps than map[string]string say nothing about their values
+		if format == "map" {
+			break
+		}
 		schema.AdditionalProperties = &spec.SchemaOrBool{
 			Schema: &spec.Schema{
 				SchemaProps: spec.SchemaProps{
@@ -240,24 +387,25 @@ func CreateSchema(fieldtype, format, desc, ref string) (spec.Schema, error) {
 				Schema: &spec.Schema{
 					SchemaProps: spec.SchemaProps{
 						Ref: spec.Ref{
-							refObj,
+							Ref: refObj,
 						},
 					},
 				},
 			}
 		}
 	case "starexpr":
-		if ref != "" {
-			refObj, err := CreateJSONRef(ref)
-			if err != nil {
-				return schema, errors.Wrapf(err, "error extracting name from json tag")
-			}
-			schema.Ref = spec.Ref{refObj}
-			// This was removed because all data is coming from that ref
-			// there is no type called "starexpr" but this is more for knowing
-			// that we need to refernce it directly
-			schema.Type = nil
+		// there is no type called "starexpr", the pointer can only be given
+		// as reference to the definition of the struct it points to
+		if ref == "" {
+			return schema, fmt.Errorf("pointer to a struct needs 'ref:' or 'k8s:' comment with the key of its definition")
+		}
+		refObj, err := CreateJSONRef(ref)
+		if err != nil {
+			return schema, errors.Wrapf(err, "error extracting name from json tag")
 		}
+		schema.Ref = spec.Ref{Ref: refObj}
+		// This was removed because all data is coming from that ref
+		schema.Type = nil
 	}
 	return schema, nil
 }
@@ -290,11 +438,20 @@ func GetStructFieldType(g interface{}) (string, string, error) {
 		// e.g.
 		// Name string `json:"name"`
 		// TODO: handle other types like int or bool
-		if v.Name == "string" {
+		switch v.Name {
+		case "string":
 			return "string", v.Name, nil
-		} else if v.Name == "int64" {
+		case "int64", "int32":
 			return "integer", v.Name, nil
-		} else {
+		case "int":
+			return "integer", "", nil
+		case "bool":
+			return "boolean", "", nil
+		case "float64":
+			return "number", "double", nil
+		case "float32":
+			return "number", "float", nil
+		default:
 			// this could cause problems for types that are identifiers and not string
 			// so not adding it now because fields that are defined in same package
 			// and embedded will cause problems e.g.
@@ -308,21 +465,18 @@ func GetStructFieldType(g interface{}) (string, string, error) {
 		// fields like following are of map type
 		// e.g.
 		// Data map[string]string `json:"data,omitempty"`
-		key, ok1 := v.Key.(*ast.Ident)
-		value, ok2 := v.Value.(*ast.Ident)
-		if ok1 && ok2 {
-			// TODO: only checking for string key and value
-			// if needed also add other types of maps
-			if key.Name == "string" && value.Name == "string" {
-				return "object", "", nil
-			} else {
-				return "", "", fmt.Errorf("map types not string")
-			}
-		} else {
-			// if maps either key or value is not identifier then
-			// maybe handle it differently
-			return "", "", fmt.Errorf("map key or value not identifier")
+		key, ok := v.Key.(*ast.Ident)
+		if !ok || key.Name != "string" {
+			return "", "", fmt.Errorf("map key not string")
+		}
+		if value, ok := v.Value.(*ast.Ident); ok && value.Name == "string" {
+			return "object", "", nil
 		}
+		// values of other types are either references given in the
+		// comments or described by the schema marker
+		// e.g.
+		// Volumes map[string]VolumeMod `json:"volumes"`
+		return "object", "map", nil
 	case *ast.ArrayType:
 		// e.g.
 		// Ports []ServicePortMod `json:"ports"`
@@ -339,13 +493,20 @@ func GetStructFieldType(g interface{}) (string, string, error) {
 
 		// If a base type is pointer that will come up here
 		// e.g. ActiveDeadlineSeconds *int64
-		_, ok := v.X.(*ast.Ident)
-		if ok {
-			return GetStructFieldType(v.X)
+		if _, ok := v.X.(*ast.Ident); ok {
+			fieldtype, format, err := GetStructFieldType(v.X)
+			if err != nil || fieldtype != "" {
+				return fieldtype, format, err
+			}
 		}
 		// e.g.
 		// ConfigMapRef *ConfigMapEnvSource `json:"configMapRef,omitempty"`
 		return "starexpr", "", nil
+	case *ast.InterfaceType:
+		// values of any type
+		// e.g.
+		// Value interface{} `json:"value"`
+		return "interface", "", nil
 	default:
 		// if none of above is satisfied then it should be added later
 		// so keeping error so that we know that it is missing
@@ -402,6 +563,10 @@ func ParseStructFieldComments(cg *ast.CommentGroup) (desc string, ref string, op
 		if strings.HasPrefix(comment, "+optional") {
 			// if the field is has optional mentioned mark the boolean as true
 			optional = true
+		} else if strings.HasPrefix(comment, SchemaMarker) || strings.HasPrefix(comment, RequiredMarker) {
+			// markers are not part of description, the schema marker
+			// is read by ApplySchemaMarker
+			continue
 		} else if strings.HasPrefix(comment, "ref:") || strings.HasPrefix(comment, "k8s:") {
 			// if this is reference either mentioned using 'ref' or 'k8s'
 			// we remove the leading 'ref' or 'k8s' and return rest
@@ -434,6 +599,8 @@ func ParseStructComments(cg *ast.CommentGroup) (kedgeSpecKey, desc string) {
 		// else it is normal comment so add it to the description
 		if strings.HasPrefix(comment, "kedgeSpec:") {
 			kedgeSpecKey = strings.TrimSpace(strings.Split(comment, ":")[1])
+		} else if strings.HasPrefix(comment, SchemaMarker) {
+			continue
 		} else {
 			desc = desc + comment + " "
 		}
@@ -441,18 +608,60 @@ func ParseStructComments(cg *ast.CommentGroup) (kedgeSpecKey, desc string) {
 	return kedgeSpecKey, strings.TrimSpace(desc)
 }
 
-func LogJson(v interface{}) {
-	b, e := json.MarshalIndent(v, "", "  ")
-	if e != nil {
-		log.Fatalln(e)
+// Returns the schema with the keywords of the schema marker in the comments
+// set on it, see SchemaMarker
+func ApplySchemaMarker(s spec.Schema, cg *ast.CommentGroup) (spec.Schema, error) {
+	if cg == nil {
+		return s, nil
 	}
-	log.Debugln(string(b))
+	var keywords []map[string]interface{}
+	for _, c := range cg.List {
+		comment := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(comment, SchemaMarker) {
+			continue
+		}
+		var k map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(comment, SchemaMarker)), &k); err != nil {
+			return s, errors.Wrap(err, "invalid schema marker")
+		}
+		keywords = append(keywords, k)
+	}
+	if len(keywords) == 0 {
+		return s, nil
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return s, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return s, err
+	}
+	for _, k := range keywords {
+		for name, v := range k {
+			if v == nil {
+				delete(m, name)
+			} else {
+				m[name] = v
+			}
+		}
+	}
+	if b, err = json.Marshal(m); err != nil {
+		return s, err
+	}
+	var result spec.Schema
+	if err := json.Unmarshal(b, &result); err != nil {
+		return s, errors.Wrap(err, "invalid schema marker")
+	}
+	return result, nil
 }
 
-func PrintJson(v interface{}) {
+func LogJson(v interface{}) {
 	b, e := json.MarshalIndent(v, "", "  ")
 	if e != nil {
-		log.Fatalln(e)
+		log.Debugln(e)
+		return
 	}
-	fmt.Println(string(b))
+	log.Debugln(string(b))
 }
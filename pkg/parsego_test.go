@@ -0,0 +1,147 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKedgeSourcesFieldTypes(t *testing.T) {
+	defs, _, err := ParseKedgeSources([]Source{{Name: "types.go", Content: []byte(`package spec
+
+// kedgeSpec: io.kedge.Field
+type Field struct {
+	// name of the field
+	Name string ` + "`json:\"name\"`" + `
+	// +optional
+	Count int ` + "`json:\"count,omitempty\"`" + `
+	// +optional
+	Replicas *int32 ` + "`json:\"replicas,omitempty\"`" + `
+	// +optional
+	Enabled *bool ` + "`json:\"enabled,omitempty\"`" + `
+	// +optional
+	Ratio float64 ` + "`json:\"ratio,omitempty\"`" + `
+	// +optional
+	Weight float32 ` + "`json:\"weight,omitempty\"`" + `
+	// +optional
+	Value interface{} ` + "`json:\"value,omitempty\"`" + `
+	// +optional
+	Labels map[string]string ` + "`json:\"labels,omitempty\"`" + `
+	// ref: io.kedge.Field
+	// +optional
+	Fields map[string]Field ` + "`json:\"fields,omitempty\"`" + `
+	// +optional
+	Extra map[string]int ` + "`json:\"extra,omitempty\"`" + `
+	// ref: io.kedge.Field
+	// +optional
+	Parent *Field ` + "`json:\"parent,omitempty\"`" + `
+}
+`)}})
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	assertJSONEqual(t, "field types", `{
+		"io.kedge.Field": {
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "description": "name of the field"},
+				"count": {"type": "integer"},
+				"replicas": {"type": "integer", "format": "int32"},
+				"enabled": {"type": "boolean"},
+				"ratio": {"type": "number", "format": "double"},
+				"weight": {"type": "number", "format": "float"},
+				"value": {},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"fields": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.kedge.Field"}},
+				"extra": {"type": "object"},
+				"parent": {"$ref": "#/definitions/io.kedge.Field"}
+			}
+		}
+	}`, defs)
+}
+
+func TestParseKedgeSourcesMarkers(t *testing.T) {
+	defs, _, err := ParseKedgeSources([]Source{{Name: "types.go", Content: []byte(`package spec
+
+// kedgeSpec: io.kedge.Container
+// container of the app
+// +schema: {"minProperties": 1, "required": null}
+type Container struct {
+	// +schema: {"enum": ["Always", "Never"]}
+	// +optional
+	PullPolicy string ` + "`json:\"pullPolicy,omitempty\"`" + `
+	// +required
+	Image string ` + "`json:\"image\"`" + `
+	// +schema: {"minimum": 1, "format": null}
+	// +required
+	Port int32 ` + "`json:\"port\"`" + `
+}
+`)}})
+	if err != nil {
+		t.Fatalf("could not parse: %v", err)
+	}
+
+	assertJSONEqual(t, "markers", `{
+		"io.kedge.Container": {
+			"description": "container of the app",
+			"minProperties": 1,
+			"properties": {
+				"pullPolicy": {"type": "string", "enum": ["Always", "Never"]},
+				"image": {"type": "string"},
+				"port": {"type": "integer", "minimum": 1}
+			}
+		}
+	}`, defs)
+}
+
+func TestParseKedgeSourcesInvalidMarker(t *testing.T) {
+	_, _, err := ParseKedgeSources([]Source{{Name: "types.go", Content: []byte(`package spec
+
+// kedgeSpec: io.kedge.Container
+type Container struct {
+	// +schema: {"enum": [
+	Image string ` + "`json:\"image\"`" + `
+}
+`)}})
+	if err == nil || !strings.Contains(err.Error(), "invalid schema marker") {
+		t.Fatalf("expected invalid schema marker error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "types.go:6") {
+		t.Errorf("expected error to point at the field, got %v", err)
+	}
+}
+
+func TestParseKedgeSourcesPointerWithoutRef(t *testing.T) {
+	for _, field := range []string{"Parent *Field", "Probe *api_v1.Probe"} {
+		_, _, err := ParseKedgeSources([]Source{{Name: "types.go", Content: []byte(`package spec
+
+// kedgeSpec: io.kedge.Field
+type Field struct {
+	// +optional
+	` + field + " `json:\"parent,omitempty\"`" + `
+}
+`)}})
+		if err == nil || !strings.Contains(err.Error(), "needs 'ref:' or 'k8s:' comment") {
+			t.Errorf("%s: expected error for pointer without reference, got %v", field, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "types.go:6") {
+			t.Errorf("%s: expected error to point at the field, got %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-openapi/spec"
+)
+
+// All the kedge definitions are added with this prefix, see 'kedgeSpec:'
+// comments in Kedge's types.go
+const KedgeKeyPrefix = "io.kedge."
+
+// Prefix of the JSON reference used to point to other definitions in the
+// same OpenAPI document
+const definitionsRefPrefix = "#/definitions/"
+
+// Returns the keys of all the definitions that are defined by Kedge, these
+// are the default roots when pruning
+func KedgeRoots(defs spec.Definitions) []string {
+	var roots []string
+	for k := range defs {
+		if strings.HasPrefix(k, KedgeKeyPrefix) {
+			roots = append(roots, k)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Given definitions and the keys of root definitions, this walks all the
+// '$ref's transitively starting from roots and returns only the definitions
+// that are reachable, if no roots are given then Kedge definitions are used
+func PruneDefinitions(defs spec.Definitions, roots []string) (spec.Definitions, error) {
+	return pruneDefinitions(defs, roots, nil)
+}
+
+// Prunes like PruneDefinitions, but if dangling is given then references to
+// definitions that are not found are given to it with the key of the
+// definition they are in, instead of failing. Roots always have to be found.
+func pruneDefinitions(defs spec.Definitions, roots []string, dangling func(from, key string)) (spec.Definitions, error) {
+	if len(roots) == 0 {
+		roots = KedgeRoots(defs)
+	}
+
+	type visit struct {
+		// definition the key is referred from, blank for roots
+		from, key string
+	}
+	pruned := spec.Definitions(make(map[string]spec.Schema))
+	missing := make(map[string]bool)
+	// queue of definition keys which are yet to be visited
+	var queue []visit
+	for _, r := range roots {
+		queue = append(queue, visit{key: r})
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if _, ok := pruned[v.key]; ok || missing[v.from+"\x00"+v.key] {
+			continue
+		}
+
+		def, ok := defs[v.key]
+		if !ok {
+			if v.from == "" || dangling == nil {
+				return nil, &DefinitionNotFoundError{Key: v.key}
+			}
+			missing[v.from+"\x00"+v.key] = true
+			dangling(v.from, v.key)
+			continue
+		}
+		pruned[v.key] = def
+		for _, ref := range SchemaRefs(def) {
+			queue = append(queue, visit{from: v.key, key: ref})
+		}
+	}
+	log.Debugf("pruned definitions from %d to %d", len(defs), len(pruned))
+	return pruned, nil
+}
+
+// Returns the keys of all the definitions that are referred by the given
+// schema, including the ones that are referred by nested schemas
+func SchemaRefs(s spec.Schema) []string {
+	var refs []string
+	walkSchema(s, func(s spec.Schema) {
+		if ref := RefKey(s.Ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	})
+	return refs
+}
+
+// Returns the definition key given JSON reference of the form
+// '#/definitions/<key>', for any other reference it returns blank string
+func RefKey(ref spec.Ref) string {
+	r := ref.String()
+	if !strings.HasPrefix(r, definitionsRefPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(r, definitionsRefPrefix)
+}
+
+// Calls the function 'f' for the given schema and every schema that
+// is nested inside of it
+func walkSchema(s spec.Schema, f func(spec.Schema)) {
+	f(s)
+
+	if s.Items != nil {
+		if s.Items.Schema != nil {
+			walkSchema(*s.Items.Schema, f)
+		}
+		for _, item := range s.Items.Schemas {
+			walkSchema(item, f)
+		}
+	}
+	for _, lists := range [][]spec.Schema{s.AllOf, s.OneOf, s.AnyOf} {
+		for _, item := range lists {
+			walkSchema(item, f)
+		}
+	}
+	if s.Not != nil {
+		walkSchema(*s.Not, f)
+	}
+	for _, maps := range []map[string]spec.Schema{s.Properties, s.PatternProperties, s.Definitions} {
+		for _, item := range maps {
+			walkSchema(item, f)
+		}
+	}
+	for _, item := range []*spec.SchemaOrBool{s.AdditionalProperties, s.AdditionalItems} {
+		if item != nil && item.Schema != nil {
+			walkSchema(*item.Schema, f)
+		}
+	}
+	for _, item := range s.Dependencies {
+		if item.Schema != nil {
+			walkSchema(*item.Schema, f)
+		}
+	}
+}
+
+// Returns copy of the schema where the function 'f' is applied to every
+// schema nested inside of it and then to the schema itself, the schema
+// given is left as it is
+func mapSchema(s spec.Schema, f func(spec.Schema) spec.Schema) spec.Schema {
+	if s.Items != nil {
+		items := &spec.SchemaOrArray{}
+		if s.Items.Schema != nil {
+			item := mapSchema(*s.Items.Schema, f)
+			items.Schema = &item
+		}
+		items.Schemas = mapSchemas(s.Items.Schemas, f)
+		s.Items = items
+	}
+	s.AllOf = mapSchemas(s.AllOf, f)
+	s.OneOf = mapSchemas(s.OneOf, f)
+	s.AnyOf = mapSchemas(s.AnyOf, f)
+	if s.Not != nil {
+		not := mapSchema(*s.Not, f)
+		s.Not = &not
+	}
+	s.Properties = mapSchemaMap(s.Properties, f)
+	s.PatternProperties = mapSchemaMap(s.PatternProperties, f)
+	s.Definitions = mapSchemaMap(s.Definitions, f)
+	s.AdditionalProperties = mapSchemaOrBool(s.AdditionalProperties, f)
+	s.AdditionalItems = mapSchemaOrBool(s.AdditionalItems, f)
+	if s.Dependencies != nil {
+		deps := make(spec.Dependencies, len(s.Dependencies))
+		for k, v := range s.Dependencies {
+			if v.Schema != nil {
+				dep := mapSchema(*v.Schema, f)
+				v.Schema = &dep
+			}
+			deps[k] = v
+		}
+		s.Dependencies = deps
+	}
+	return f(s)
+}
+
+func mapSchemas(list []spec.Schema, f func(spec.Schema) spec.Schema) []spec.Schema {
+	if list == nil {
+		return nil
+	}
+	mapped := make([]spec.Schema, len(list))
+	for i, item := range list {
+		mapped[i] = mapSchema(item, f)
+	}
+	return mapped
+}
+
+func mapSchemaMap(m map[string]spec.Schema, f func(spec.Schema) spec.Schema) map[string]spec.Schema {
+	if m == nil {
+		return nil
+	}
+	mapped := make(map[string]spec.Schema, len(m))
+	for k, item := range m {
+		mapped[k] = mapSchema(item, f)
+	}
+	return mapped
+}
+
+func mapSchemaOrBool(s *spec.SchemaOrBool, f func(spec.Schema) spec.Schema) *spec.SchemaOrBool {
+	if s == nil {
+		return nil
+	}
+	mapped := &spec.SchemaOrBool{Allows: s.Allows}
+	if s.Schema != nil {
+		item := mapSchema(*s.Schema, f)
+		mapped.Schema = &item
+	}
+	return mapped
+}
+
+// Removes everything from the OpenAPI document which is not needed by
+// Kedge and keeps only definitions that are reachable from roots, the rest
+// like 'paths' or 'securityDefinitions' describe the Kubernetes API server
+// and have nothing to do with Kedge. References to definitions that are not
+// defined, e.g. in upstream schemas, are reported and the definitions that
+// can be reached are kept anyway.
+func PruneDocument(doc *spec.Swagger, roots []string, report func(Diagnostic)) error {
+	defs, err := pruneDefinitions(doc.Definitions, roots, func(from, key string) {
+		report(Diagnostic{
+			Rule:     RuleDanglingRef,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("%q refers to %q which is not defined, the reference is left dangling", from, key),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	*doc = spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Swagger:     doc.Swagger,
+			Info:        doc.Info,
+			Paths:       &spec.Paths{},
+			Definitions: defs,
+		},
+	}
+	return nil
+}
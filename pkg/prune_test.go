@@ -0,0 +1,131 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+const testPruneDefinitions = `{
+	"io.kedge.App": {"oneOf": [{"$ref": "#/definitions/io.kedge.DeploymentSpecMod"}, {"$ref": "#/definitions/io.kedge.JobSpecMod"}]},
+	"io.kedge.DeploymentSpecMod": {"properties": {"containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.Container"}}}},
+	"io.kedge.JobSpecMod": {"properties": {"labels": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.Label"}}}},
+	"io.k8s.Container": {"properties": {"probe": {"allOf": [{"$ref": "#/definitions/io.k8s.Probe"}]}, "self": {"$ref": "#/definitions/io.k8s.Container"}}},
+	"io.k8s.Probe": {"properties": {"port": {"not": {"$ref": "#/definitions/io.k8s.Port"}}}},
+	"io.k8s.Port": {"type": "integer"},
+	"io.k8s.Label": {"type": "string"},
+	"io.k8s.Unused": {"properties": {"probe": {"$ref": "#/definitions/io.k8s.Probe"}}}
+}`
+
+func TestPruneDefinitions(t *testing.T) {
+	defs := testDefinitions(t, testPruneDefinitions)
+	tests := []struct {
+		name     string
+		roots    []string
+		expected []string
+	}{
+		{
+			"kedge roots",
+			nil,
+			[]string{
+				"io.k8s.Container", "io.k8s.Label", "io.k8s.Port", "io.k8s.Probe",
+				"io.kedge.App", "io.kedge.DeploymentSpecMod", "io.kedge.JobSpecMod",
+			},
+		},
+		{
+			"items, allOf and not",
+			[]string{"io.kedge.DeploymentSpecMod"},
+			[]string{"io.k8s.Container", "io.k8s.Port", "io.k8s.Probe", "io.kedge.DeploymentSpecMod"},
+		},
+		{
+			"additional properties",
+			[]string{"io.kedge.JobSpecMod"},
+			[]string{"io.k8s.Label", "io.kedge.JobSpecMod"},
+		},
+		{
+			"upstream root",
+			[]string{"io.k8s.Unused"},
+			[]string{"io.k8s.Port", "io.k8s.Probe", "io.k8s.Unused"},
+		},
+	}
+
+	for _, test := range tests {
+		pruned, err := PruneDefinitions(defs, test.roots)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if keys := sortedSchemaKeys(pruned); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%s: expected definitions %v, got %v", test.name, test.expected, keys)
+		}
+	}
+}
+
+func TestPruneDefinitionsNotFound(t *testing.T) {
+	defs := testDefinitions(t, `{"io.kedge.App": {"$ref": "#/definitions/io.k8s.Missing"}}`)
+	tests := []struct {
+		name    string
+		roots   []string
+		missing string
+	}{
+		{"missing root", []string{"io.kedge.Other"}, "io.kedge.Other"},
+		{"missing reference", nil, "io.k8s.Missing"},
+	}
+	for _, test := range tests {
+		_, err := PruneDefinitions(defs, test.roots)
+		notFound, ok := err.(*DefinitionNotFoundError)
+		if !ok || notFound.Key != test.missing {
+			t.Errorf("%s: expected definition %q not to be found, got %v", test.name, test.missing, err)
+		}
+	}
+}
+
+func TestPruneDocumentDangling(t *testing.T) {
+	doc := &spec.Swagger{SwaggerProps: spec.SwaggerProps{Definitions: testDefinitions(t, `{
+		"io.kedge.App": {"properties": {"container": {"$ref": "#/definitions/io.k8s.Container"}}},
+		"io.k8s.Container": {"properties": {
+			"probe": {"$ref": "#/definitions/io.k8s.Probe"},
+			"lifecycle": {"$ref": "#/definitions/io.k8s.Lifecycle"},
+			"handler": {"$ref": "#/definitions/io.k8s.Lifecycle"}
+		}},
+		"io.k8s.Probe": {"type": "object"},
+		"io.k8s.Unused": {"type": "object"}
+	}`)}}
+
+	var diags []Diagnostic
+	if err := PruneDocument(doc, nil, func(d Diagnostic) { diags = append(diags, d) }); err != nil {
+		t.Fatalf("expected dangling reference to be reported, got %v", err)
+	}
+	expected := []string{"io.k8s.Container", "io.k8s.Probe", "io.kedge.App"}
+	if keys := sortedSchemaKeys(doc.Definitions); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected definitions %v, got %v", expected, keys)
+	}
+	// reported once however many times it is referred to
+	if len(diags) != 1 || diags[0].Rule != RuleDanglingRef || !strings.Contains(diags[0].Message, `"io.k8s.Lifecycle"`) {
+		t.Errorf("expected a dangling reference to io.k8s.Lifecycle, got %v", diags)
+	}
+
+	// roots are given explicitly, so they have to be there
+	err := PruneDocument(doc, []string{"io.k8s.Missing"}, func(Diagnostic) {})
+	if notFound, ok := err.(*DefinitionNotFoundError); !ok || notFound.Key != "io.k8s.Missing" {
+		t.Errorf("expected root not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Version of SARIF that diagnostics are written in
+const SARIFVersion = "2.1.0"
+
+const (
+	sarifSchema   = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/cos02/schemas/sarif-schema-2.1.0.json"
+	sarifToolName = "schemagen"
+	sarifToolURI  = "https://github.com/kedgeproject/json-schema-generator"
+)
+
+// The types below are the parts of SARIF log format that are used, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID string `json:"ruleId"`
+	// index of the rule in the rules of the driver, not given for rules
+	// that are not there
+	RuleIndex *int            `json:"ruleIndex,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Writes the diagnostics as a SARIF 2.1.0 log with a single run, every rule
+// schemagen has is described in the log so that results can refer to them
+func WriteSARIF(w io.Writer, diags []Diagnostic) error {
+	rules := AllRules()
+	index := make(map[string]int)
+	driver := sarifDriver{
+		Name:           sarifToolName,
+		Version:        Version,
+		InformationURI: sarifToolURI,
+		Rules:          []sarifRule{},
+	}
+	for i, r := range rules {
+		index[r.Name] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   r.Name,
+			ShortDescription:     sarifMessage{Text: r.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(r.Severity)},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, d := range diags {
+		r := sarifResult{
+			RuleID:  d.Rule,
+			Level:   sarifLevel(d.Severity),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if i, ok := index[d.Rule]; ok {
+			r.RuleIndex = &i
+		}
+		if d.File != "" {
+			loc := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: sarifURI(d.File)},
+				},
+			}
+			if d.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			r.Locations = []sarifLocation{loc}
+		}
+		results = append(results, r)
+	}
+
+	return WriteJSON(w, sarifLog{
+		Schema:  sarifSchema,
+		Version: SARIFVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: driver},
+			Results: results,
+		}},
+	})
+}
+
+// SARIF levels are named the same as the severities
+func sarifLevel(severity string) string {
+	if severity == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Returns the file as URI, relative paths are kept relative so that they
+// resolve against the repository the tool ran in. Characters that are not
+// allowed in URIs, like spaces, are percent-encoded.
+func sarifURI(file string) string {
+	abs := filepath.IsAbs(file)
+	file = filepath.ToSlash(file)
+	if !abs {
+		return (&url.URL{Path: file}).String()
+	}
+	// Windows paths start with the drive letter
+	if !strings.HasPrefix(file, "/") {
+		file = "/" + file
+	}
+	return (&url.URL{Scheme: "file", Path: file}).String()
+}
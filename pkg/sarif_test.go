@@ -0,0 +1,57 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteSARIF(&b, []Diagnostic{
+		{Rule: RuleSourceError, Severity: SeverityError, Message: "kubernetes: could not read", File: "/tmp/k8s schemas/swagger#1.json"},
+		{Rule: RuleMissingDescription, Severity: SeverityWarning, Message: "field Name of App has no description", File: "spec/my types.go", Line: 12, Column: 2},
+		// rules of other tools are not described, so results only have
+		// their identifiers
+		{Rule: "other-rule", Severity: SeverityError, Message: "no location"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "diagnostics.sarif", b.Bytes())
+}
+
+func TestSARIFURI(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"types.go", "types.go"},
+		{"spec/my types.go", "spec/my%20types.go"},
+		{"/src/kedge/types.go", "file:///src/kedge/types.go"},
+		{"/src/100% kedge/types#1.go", "file:///src/100%25%20kedge/types%231.go"},
+		// the colon would be read as the scheme
+		{"c:types.go", "./c:types.go"},
+	}
+
+	for _, test := range tests {
+		if uri := sarifURI(test.file); uri != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.file, test.expected, uri)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Version the schema is served under when no Kubernetes releases are given
+const DefaultServeVersion = "latest"
+
+// Path of the endpoint that validates Kedge files, at the root and under
+// every version
+const ServeValidatePath = "validate"
+
+// Files sent to be validated can't be bigger than this
+const maxValidateSize = 10 << 20
+
+// SchemaServer serves the generated schemas over HTTP. Every version is
+// served under its own path, '/v1.7/openapi.json' has the OpenAPI schema and
+// '/v1.7/app.json' and the other files of the definitions have them as JSON
+// Schema, the same files '--out-dir' writes, with '/index.json'
+// listing the versions. Files are served with ETags and headers that allow any origin to
+// fetch them. Kedge files posted to '/validate' are validated against the
+// last version, and to '/v1.7/validate' against that version.
+type SchemaServer struct {
+	// Load returns the config, it is called again whenever the inputs
+	// change, the schema is generated for every Kubernetes release in it
+	Load func() (*Config, error)
+	// Config file to watch, blank if there is none
+	ConfigFile string
+	// How often files are checked, DefaultWatchInterval if not given
+	Interval time.Duration
+	// Version the schema is served under when config has no releases,
+	// DefaultServeVersion if not given
+	Version string
+	// Draft of JSON Schema the definitions are served in, one of Drafts, if
+	// not given they are served as they are in the OpenAPI schema
+	Draft string
+	// URL the server is reached at, every file gets its URL as identifier
+	// if it is given
+	IDBase string
+	// Objects don't allow unknown fields when validating, see NewValidator
+	Strict bool
+	// Called with the result of every generation
+	OnResult func(ServeResult)
+
+	cfg      *Config
+	mu       sync.RWMutex
+	snapshot *serveSnapshot
+}
+
+// ServeResult is what happened in one generation of the SchemaServer
+type ServeResult struct {
+	Time time.Time
+	// Versions that are served
+	Versions []string
+	// Error that stopped generation, the previous schemas are still served
+	Err error
+}
+
+// ValidationResponse is what the validate endpoint responds with
+type ValidationResponse struct {
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors"`
+	// Problem that stopped validation, like YAML that can't be parsed
+	Error string `json:"error,omitempty"`
+}
+
+// serveSnapshot has everything served from one generation, it is never
+// changed so that requests can use it while the next one is generated
+type serveSnapshot struct {
+	// files by their path without the leading '/'
+	files      map[string]servedFile
+	validators map[string]*Validator
+	// versions in the order they are given, the last one is validated
+	// against when no version is given
+	versions []string
+}
+
+type servedFile struct {
+	content []byte
+	etag    string
+}
+
+// Generates the schemas and then again every time any of the inputs
+// change, until stop is closed. Requests are served while generating,
+// with the schemas generated before.
+func (s *SchemaServer) Run(stop <-chan struct{}) error {
+	pollInputs(s.Interval, stop, s.inputs, func() {
+		res := ServeResult{Time: time.Now()}
+		snapshot, err := s.generate()
+		if err != nil {
+			res.Err = err
+		} else {
+			s.mu.Lock()
+			s.snapshot = snapshot
+			s.mu.Unlock()
+			res.Versions = snapshot.versions
+		}
+		if s.OnResult != nil {
+			s.OnResult(res)
+		}
+	})
+	return nil
+}
+
+func (s *SchemaServer) inputs() []string {
+	return generationInputs(s.ConfigFile, s.cfg)
+}
+
+// Generates everything that is served for every version
+func (s *SchemaServer) generate() (*serveSnapshot, error) {
+	cfg, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	// the inputs of the new config are watched, even if they are wrong
+	s.cfg = cfg
+	if err := cfg.ValidateInputs(); err != nil {
+		return nil, err
+	}
+	if err := CheckDraft(s.Draft); err != nil {
+		return nil, err
+	}
+
+	releases := cfg.Upstream.Releases
+	if len(releases) == 0 {
+		version := s.Version
+		if version == "" {
+			version = DefaultServeVersion
+		}
+		r, err := ParseRelease(version + "=" + cfg.Upstream.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		releases = []Release{r}
+	}
+
+	snapshot := &serveSnapshot{
+		files:      make(map[string]servedFile),
+		validators: make(map[string]*Validator),
+	}
+	index := MatrixIndex{}
+	for _, r := range releases {
+		if _, ok := snapshot.validators[r.Version]; ok {
+			return nil, fmt.Errorf("version %q given more than once", r.Version)
+		}
+		if err := s.generateVersion(snapshot, cfg, r); err != nil {
+			return nil, errors.Wrapf(err, "version %s", r.Version)
+		}
+		index.Versions = append(index.Versions, MatrixVersion{
+			Version:          r.Version,
+			KubernetesSchema: r.Schema,
+			OpenAPI:          path.Join(r.Version, MatrixOpenAPIFile),
+		})
+		snapshot.versions = append(snapshot.versions, r.Version)
+	}
+	if err := snapshot.add(MatrixIndexFile, index); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Generates the files of a single version and adds them to the snapshot
+func (s *SchemaServer) generateVersion(snapshot *serveSnapshot, cfg *Config, r Release) error {
+	c := *cfg
+	c.Upstream.Kubernetes = r.Schema
+	// all definitions are needed to validate, whatever config says about
+	// pruning
+	opts := c.GeneratorOptions()
+	opts.Prune, opts.Roots = false, nil
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+	api, err := g.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := snapshot.add(path.Join(r.Version, MatrixOpenAPIFile), api); err != nil {
+		return err
+	}
+	files, index := SplitDefinitions(api.Definitions, FormatJSON)
+	index.Bundle = SplitBundleFile
+	for name, f := range files {
+		doc, err := JSONSchemaDocument(f, s.Draft, draftID(s.IDBase, path.Join(r.Version, name)))
+		if err != nil {
+			return errors.Wrapf(err, "%q", name)
+		}
+		if err := snapshot.add(path.Join(r.Version, name), doc); err != nil {
+			return err
+		}
+	}
+	bundle, err := JSONSchemaDocument(BundleDefinitions(api.Definitions, s.Draft), s.Draft, draftID(s.IDBase, path.Join(r.Version, index.Bundle)))
+	if err != nil {
+		return err
+	}
+	if err := snapshot.add(path.Join(r.Version, index.Bundle), bundle); err != nil {
+		return err
+	}
+	if err := snapshot.add(path.Join(r.Version, SplitIndexFile), index); err != nil {
+		return err
+	}
+
+	snapshot.validators[r.Version] = NewValidator(api.Definitions, s.Strict)
+	return nil
+}
+
+// Adds v as JSON file with the given path
+func (snapshot *serveSnapshot) add(name string, v interface{}) error {
+	content, err := Marshal(v, FormatJSON)
+	if err != nil {
+		return errors.Wrapf(err, "%q", name)
+	}
+	sum := sha256.Sum256(content)
+	snapshot.files[name] = servedFile{
+		content: content,
+		etag:    `"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+	return nil
+}
+
+// Serves the generated files and validates posted Kedge files
+func (s *SchemaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	// schemas are public, editors and browsers fetch them from anywhere
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Expose-Headers", "ETag")
+	if r.Method == http.MethodOptions {
+		h.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+		h.Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.mu.RLock()
+	snapshot := s.snapshot
+	s.mu.RUnlock()
+	if snapshot == nil {
+		serveError(w, http.StatusServiceUnavailable, "schemas are not generated yet")
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = MatrixIndexFile
+	}
+	if version, ok := validatePath(name); ok {
+		if version == "" {
+			version = snapshot.versions[len(snapshot.versions)-1]
+		}
+		s.serveValidate(w, r, snapshot, version)
+		return
+	}
+
+	f, ok := snapshot.files[name]
+	if !ok {
+		serveError(w, http.StatusNotFound, fmt.Sprintf("%q not found", "/"+name))
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.Set("Allow", "GET, HEAD, OPTIONS")
+		serveError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	h.Set("ETag", f.etag)
+	// the schemas change whenever the sources do
+	h.Set("Cache-Control", "no-cache")
+	// answers If-None-Match with 304 and HEAD without the body
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.content))
+}
+
+// Returns the version of the validate path, blank for the one without
+// version, and false if the path is not a validate path
+func validatePath(name string) (string, bool) {
+	if name == ServeValidatePath {
+		return "", true
+	}
+	if strings.HasSuffix(name, "/"+ServeValidatePath) {
+		version := strings.TrimSuffix(name, "/"+ServeValidatePath)
+		return version, !strings.Contains(version, "/")
+	}
+	return "", false
+}
+
+// Validates the Kedge file in the body of the request against the version,
+// the definition to validate against can be given with the 'root' query
+// parameter and defaults to AppKey
+func (s *SchemaServer) serveValidate(w http.ResponseWriter, r *http.Request, snapshot *serveSnapshot, version string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		serveError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed, post the file to validate", r.Method))
+		return
+	}
+	v, ok := snapshot.validators[version]
+	if !ok {
+		serveError(w, http.StatusNotFound, fmt.Sprintf("version %q not found", version))
+		return
+	}
+	root := r.URL.Query().Get("root")
+	if root == "" {
+		root = AppKey
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxValidateSize)
+	errs, err := v.ValidateReader(root, "", body)
+	res := ValidationResponse{Valid: err == nil && len(errs) == 0, Errors: errs}
+	if res.Errors == nil {
+		res.Errors = []ValidationError{}
+	}
+	status := http.StatusOK
+	if err != nil {
+		res.Error = errors.Cause(err).Error()
+		status = http.StatusBadRequest
+	}
+	serveJSON(w, status, res)
+}
+
+func serveError(w http.ResponseWriter, status int, message string) {
+	serveJSON(w, status, map[string]string{"error": message})
+}
+
+func serveJSON(w http.ResponseWriter, status int, v interface{}) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(content, '\n'))
+}
+
+// Writes short human readable summary of the serve result
+func WriteServeResultText(w io.Writer, r ServeResult) error {
+	stamp := r.Time.Format("15:04:05")
+	var err error
+	switch {
+	case r.Err == nil:
+		_, err = fmt.Fprintf(w, "[%s] serving %s\n", stamp, strings.Join(r.Versions, ", "))
+	default:
+		if d, ok := ErrorDiagnostic(r.Err); ok {
+			_, err = fmt.Fprintf(w, "[%s] %s\n", stamp, d)
+		} else {
+			_, err = fmt.Fprintf(w, "[%s] error: %v\n", stamp, r.Err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Starts SchemaServer serving the test schema as 'v1.7' and the newer one as
+// 'v1.8', it is stopped by the returned function
+func newTestSchemaServer(t *testing.T) (*httptest.Server, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "schemagen-serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, dir)
+	cfg.Upstream.Releases = []Release{
+		{Version: "v1.7", Schema: cfg.Upstream.Kubernetes},
+		{Version: "v1.8", Schema: testNewerKubernetesSchema(t, dir)},
+	}
+	s := &SchemaServer{
+		Load:   func() (*Config, error) { return cfg, nil },
+		Strict: true,
+	}
+	snapshot, err := s.generate()
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("could not generate: %v", err)
+	}
+	s.snapshot = snapshot
+	server := httptest.NewServer(s)
+	return server, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSchemaServerETag(t *testing.T) {
+	server, stop := newTestSchemaServer(t)
+	defer stop()
+
+	res, err := http.Get(server.URL + "/v1.8/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if !strings.Contains(string(content), "workingDir") {
+		t.Errorf("expected the schema of v1.8, got %s", content)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		etag     string
+		expected int
+	}{
+		{"same file", "/v1.8/openapi.json", etag, http.StatusNotModified},
+		{"other version", "/v1.7/openapi.json", etag, http.StatusOK},
+		{"changed", "/v1.8/openapi.json", `"stale"`, http.StatusOK},
+		{"missing", "/v1.9/openapi.json", etag, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(http.MethodGet, server.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", test.etag)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != test.expected {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.expected, res.StatusCode)
+		}
+		if res.StatusCode == http.StatusNotModified && len(content) != 0 {
+			t.Errorf("%s: expected no body, got %s", test.name, content)
+		}
+	}
+}
+
+func TestSchemaServerCORS(t *testing.T) {
+	server, stop := newTestSchemaServer(t)
+	defer stop()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+		// headers expected in the response
+		headers map[string]string
+	}{
+		{
+			"preflight",
+			http.MethodOptions,
+			"/v1.7/validate",
+			http.StatusNoContent,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST, OPTIONS",
+				"Access-Control-Allow-Headers": "Content-Type, If-None-Match",
+			},
+		},
+		{
+			"file",
+			http.MethodGet,
+			"/index.json",
+			http.StatusOK,
+			map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "ETag",
+				"Content-Type":                  "application/json",
+			},
+		},
+		{
+			"error",
+			http.MethodGet,
+			"/missing.json",
+			http.StatusNotFound,
+			map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, server.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", "https://editor.example.com")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != test.expected {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.expected, res.StatusCode)
+		}
+		for header, expected := range test.headers {
+			if actual := res.Header.Get(header); actual != expected {
+				t.Errorf("%s: expected %s to be %q, got %q", test.name, header, expected, actual)
+			}
+		}
+	}
+}
+
+func TestSchemaServerValidate(t *testing.T) {
+	server, stop := newTestSchemaServer(t)
+	defer stop()
+
+	const doc = `
+name: web
+containers:
+- name: web
+  image: nginx
+  workingDir: /srv
+`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+		valid    bool
+	}{
+		{"field of the version", http.MethodPost, "/v1.8/validate", doc, http.StatusOK, true},
+		{"field of newer version", http.MethodPost, "/v1.7/validate", doc, http.StatusOK, false},
+		{"last version", http.MethodPost, "/validate", doc, http.StatusOK, true},
+		{"unknown version", http.MethodPost, "/v1.9/validate", doc, http.StatusNotFound, false},
+		{"not posted", http.MethodGet, "/v1.8/validate", "", http.StatusMethodNotAllowed, false},
+		{"invalid YAML", http.MethodPost, "/v1.8/validate", "name: [web", http.StatusBadRequest, false},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual ValidationResponse
+		err = json.NewDecoder(res.Body).Decode(&actual)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if res.StatusCode != test.expected {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.expected, res.StatusCode)
+		}
+		if actual.Valid != test.valid {
+			t.Errorf("%s: expected valid to be %v, got %+v", test.name, test.valid, actual)
+		}
+	}
+}
+
+func TestSchemaServerValidateLimit(t *testing.T) {
+	server, stop := newTestSchemaServer(t)
+	defer stop()
+
+	// valid YAML that is only too big
+	body := bytes.NewBufferString("name: web\n")
+	for body.Len() <= maxValidateSize {
+		body.WriteString("# padding padding padding padding padding padding\n")
+	}
+	res, err := http.Post(server.URL+"/validate", "application/yaml", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual ValidationResponse
+	err = json.NewDecoder(res.Body).Decode(&actual)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest || actual.Valid || !strings.Contains(actual.Error, "too large") {
+		t.Errorf("expected file over the limit to be rejected, got %d %+v", res.StatusCode, actual)
+	}
+
+	res, err = http.Post(server.URL+"/validate", "application/yaml", strings.NewReader("name: web\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected file under the limit to be validated, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Name of the file that lists the files every definition was written to
+const SplitIndexFile = "index.json"
+
+// Name of the file that has all the definitions
+const SplitBundleFile = "bundle.json"
+
+// SplitIndex lists the definitions written to their own files
+type SplitIndex struct {
+	Definitions []SplitDefinition `json:"definitions" yaml:"definitions"`
+	// File with all the definitions, blank if it was not written
+	Bundle string `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+}
+
+// SplitDefinition is a single definition in the SplitIndex
+type SplitDefinition struct {
+	Key string `json:"key" yaml:"key"`
+	// Path of the file relative to the directory of the index
+	File string `json:"file" yaml:"file"`
+}
+
+// Returns the names of the files every definition is written to. The file
+// is named after the last part of the key e.g. 'deploymentspecmod.json' for
+// 'io.kedge.DeploymentSpecMod', when more keys end the same then all of
+// them are named after the whole key instead.
+func SplitFileNames(keys []string, format string) map[string]string {
+	ext := FormatExtension(format)
+	short := func(key string) string {
+		return strings.ToLower(key[strings.LastIndex(key, ".")+1:])
+	}
+	count := make(map[string]int)
+	for _, k := range keys {
+		count[short(k)]++
+	}
+
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	names := make(map[string]string, len(keys))
+	used := make(map[string]bool, len(keys))
+	for _, k := range sorted {
+		name := short(k)
+		if count[name] > 1 {
+			name = strings.ToLower(k)
+		}
+		// keys that differ only in case still need their own files
+		unique := name
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s-%d", name, i)
+		}
+		used[unique] = true
+		names[k] = unique + ext
+	}
+	return names
+}
+
+// Returns every definition as its own schema, keyed by the file it is
+// written to, with references to other definitions rewritten to the files
+// they are written to e.g. './objectmeta.json'. References to definitions
+// that are not given are left as they are.
+func SplitDefinitions(defs spec.Definitions, format string) (map[string]spec.Schema, SplitIndex) {
+	keys := sortedSchemaKeys(defs)
+	names := SplitFileNames(keys, format)
+
+	files := make(map[string]spec.Schema, len(defs))
+	index := SplitIndex{Definitions: []SplitDefinition{}}
+	for _, k := range keys {
+		files[names[k]] = replaceDefinitionRefs(defs[k], func(key string) (string, bool) {
+			name, ok := names[key]
+			return "./" + name, ok
+		})
+		index.Definitions = append(index.Definitions, SplitDefinition{Key: k, File: names[k]})
+	}
+	return files, index
+}
+
+// Returns the definitions as a single document with all of them under
+// '$defs', or under 'definitions' for drafts before 2019-09, and the
+// references rewritten to point there
+func BundleDefinitions(defs spec.Definitions, draft string) map[string]interface{} {
+	keyword := DraftDefinitionsKeyword(draft)
+	bundled := make(map[string]spec.Schema, len(defs))
+	for k, v := range defs {
+		bundled[k] = replaceDefinitionRefs(v, func(key string) (string, bool) {
+			_, ok := defs[key]
+			return "#/" + keyword + "/" + key, ok
+		})
+	}
+	return map[string]interface{}{keyword: bundled}
+}
+
+// Writes every definition to its own file in the definition directory with
+// the index of them, and the bundle of all of them if asked for. Files are
+// JSON Schema documents of the draft with identifiers under the base URL
+// if they are given. Files listed by the index that was written before and
+// are not written anymore are removed. When only checking, the files that
+// are not up to date are recorded in drift.
+func writeSplit(defs spec.Definitions, out OutputConfig, drift *DriftError) error {
+	dir, format, check := out.OutDir, out.Format, out.Check
+	files, index := SplitDefinitions(defs, format)
+	indexFile := filepath.Join(dir, withExtension(SplitIndexFile, format))
+	if out.Bundle {
+		index.Bundle = withExtension(SplitBundleFile, format)
+	}
+
+	previous, err := readSplitIndex(indexFile)
+	if err != nil {
+		return err
+	}
+	if !check {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "could not create directory %q", dir)
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc, err := JSONSchemaDocument(files[name], out.Draft, draftID(out.IDBase, name))
+		if err != nil {
+			return errors.Wrapf(err, "%q", name)
+		}
+		if err := writeOutput(filepath.Join(dir, name), doc, format, check, drift); err != nil {
+			return err
+		}
+	}
+	if out.Bundle {
+		doc, err := JSONSchemaDocument(BundleDefinitions(defs, out.Draft), out.Draft, draftID(out.IDBase, index.Bundle))
+		if err != nil {
+			return errors.Wrapf(err, "%q", index.Bundle)
+		}
+		if err := writeOutput(filepath.Join(dir, index.Bundle), doc, format, check, drift); err != nil {
+			return err
+		}
+	}
+	if err := writeOutput(indexFile, index, format, check, drift); err != nil {
+		return err
+	}
+
+	// files of definitions that are gone would be published forever
+	written := make(map[string]bool)
+	for _, d := range index.Definitions {
+		written[d.File] = true
+	}
+	written[index.Bundle] = true
+	stale := []string{previous.Bundle}
+	for _, d := range previous.Definitions {
+		stale = append(stale, d.File)
+	}
+	for _, name := range stale {
+		// only plain file names are removed, whatever the index says
+		if name == "" || written[name] || name != filepath.Base(name) {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		if _, err := os.Stat(filename); err != nil {
+			continue
+		}
+		if check {
+			drift.Files = append(drift.Files, filename)
+			continue
+		}
+		if err := os.Remove(filename); err != nil {
+			return errors.Wrapf(err, "could not remove stale file %q", filename)
+		}
+	}
+	return nil
+}
+
+// Reads the index written earlier, the index is empty if there is none
+func readSplitIndex(filename string) (SplitIndex, error) {
+	var index SplitIndex
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return index, errors.Wrapf(err, "could not read file %q", filename)
+	}
+	// JSON is YAML too
+	if err := yaml.Unmarshal(content, &index); err != nil {
+		return index, errors.Wrapf(err, "could not parse index %q", filename)
+	}
+	return index, nil
+}
+
+// Returns copy of the schema where references to definitions are replaced
+// with what the function 'ref' returns for their key, unless it returns
+// false
+func replaceDefinitionRefs(s spec.Schema, ref func(key string) (string, bool)) spec.Schema {
+	return mapSchema(s, func(s spec.Schema) spec.Schema {
+		if key := RefKey(s.Ref); key != "" {
+			if r, ok := ref(key); ok {
+				s.Ref = spec.MustCreateRef(r)
+			}
+		}
+		return s
+	})
+}
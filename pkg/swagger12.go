@@ -0,0 +1,119 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Types of Swagger 1.2 properties that are not models
+var swagger12Primitives = map[string]bool{
+	"integer": true,
+	"number":  true,
+	"string":  true,
+	"boolean": true,
+	"array":   true,
+	"object":  true,
+}
+
+// OpenShift publishes its schema as Swagger 1.2, e.g. 'oapi-v1.json' that
+// 'schemagen fetch' downloads. If content is such a document, this returns
+// it as OpenAPI with the models as definitions named after their ids, the
+// same as 'api-spec-converter --from=swagger_1 --to=swagger_2' does, and
+// true. Other documents are not changed.
+func convertSwagger12(content []byte) ([]byte, bool, error) {
+	var doc struct {
+		SwaggerVersion string                            `json:"swaggerVersion"`
+		APIVersion     string                            `json:"apiVersion"`
+		Models         map[string]map[string]interface{} `json:"models"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil || doc.SwaggerVersion == "" {
+		// not Swagger 1.2, errors are reported when it is read as OpenAPI
+		return content, false, nil
+	}
+	if doc.SwaggerVersion != "1.2" {
+		return nil, false, fmt.Errorf("unsupported Swagger version %q", doc.SwaggerVersion)
+	}
+
+	defs := make(map[string]interface{}, len(doc.Models))
+	for id, model := range doc.Models {
+		def := map[string]interface{}{"type": "object"}
+		for _, k := range []string{"description", "required"} {
+			if v, ok := model[k]; ok {
+				def[k] = v
+			}
+		}
+		if props, ok := model["properties"].(map[string]interface{}); ok {
+			converted := make(map[string]interface{}, len(props))
+			for name, p := range props {
+				if prop, ok := p.(map[string]interface{}); ok {
+					converted[name] = convertSwagger12Property(prop)
+				}
+			}
+			def["properties"] = converted
+		}
+		defs[id] = def
+	}
+
+	out, err := json.Marshal(map[string]interface{}{
+		"swagger":     "2.0",
+		"info":        map[string]interface{}{"title": "", "version": doc.APIVersion},
+		"paths":       map[string]interface{}{},
+		"definitions": defs,
+	})
+	return out, true, err
+}
+
+// Returns the Swagger 1.2 property as OpenAPI schema, models are referred to
+// by their ids and limits are strings in Swagger 1.2
+func convertSwagger12Property(p map[string]interface{}) map[string]interface{} {
+	s := make(map[string]interface{}, len(p))
+	for k, v := range p {
+		switch k {
+		case "$ref":
+			if ref, ok := v.(string); ok {
+				s["$ref"] = definitionsRefPrefix + ref
+			}
+		case "type":
+			t, _ := v.(string)
+			switch {
+			case swagger12Primitives[t]:
+				s["type"] = t
+			case t != "" && t != "any" && t != "void":
+				// models can be given as type too
+				s["$ref"] = definitionsRefPrefix + t
+			}
+		case "items":
+			if items, ok := v.(map[string]interface{}); ok {
+				s["items"] = convertSwagger12Property(items)
+			}
+		case "minimum", "maximum":
+			if str, ok := v.(string); ok {
+				if n, err := strconv.ParseFloat(str, 64); err == nil {
+					s[k] = n
+				}
+				continue
+			}
+			s[k] = v
+		default:
+			s[k] = v
+		}
+	}
+	return s
+}
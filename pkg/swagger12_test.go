@@ -0,0 +1,93 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConvertSwagger12(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		converted bool
+		expected  string
+	}{
+		{
+			"swagger 1.2",
+			`{
+				"swaggerVersion": "1.2",
+				"apiVersion": "v1",
+				"models": {
+					"v1.Route": {
+						"id": "v1.Route",
+						"description": "route",
+						"required": ["spec"],
+						"properties": {
+							"spec": {"$ref": "v1.RouteSpec"},
+							"ports": {"type": "array", "items": {"type": "v1.RoutePort"}},
+							"weight": {"type": "integer", "format": "int32", "minimum": "0", "maximum": "256"},
+							"any": {"type": "any", "description": "anything"}
+						}
+					}
+				}
+			}`,
+			true,
+			`{
+				"swagger": "2.0",
+				"info": {"title": "", "version": "v1"},
+				"paths": {},
+				"definitions": {
+					"v1.Route": {
+						"type": "object",
+						"description": "route",
+						"required": ["spec"],
+						"properties": {
+							"spec": {"$ref": "#/definitions/v1.RouteSpec"},
+							"ports": {"type": "array", "items": {"$ref": "#/definitions/v1.RoutePort"}},
+							"weight": {"type": "integer", "format": "int32", "minimum": 0, "maximum": 256},
+							"any": {"description": "anything"}
+						}
+					}
+				}
+			}`,
+		},
+		{
+			"openapi",
+			`{"swagger": "2.0", "definitions": {}}`,
+			false,
+			`{"swagger": "2.0", "definitions": {}}`,
+		},
+	}
+
+	for _, test := range tests {
+		out, converted, err := convertSwagger12([]byte(test.content))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if converted != test.converted {
+			t.Errorf("%s: expected converted to be %v", test.name, test.converted)
+		}
+		assertJSONEqual(t, test.name, test.expected, json.RawMessage(out))
+	}
+
+	if _, _, err := convertSwagger12([]byte(`{"swaggerVersion": "1.1"}`)); err == nil {
+		t.Errorf("expected error converting unsupported Swagger version")
+	}
+}
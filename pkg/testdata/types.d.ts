@@ -0,0 +1,46 @@
+// Code generated by schemagen gen-ts. DO NOT EDIT.
+
+export interface K8sContainer {
+  args?: (string | number)[];
+  image: string;
+  [key: string]: unknown;
+}
+
+export interface ObjectMeta {
+  name?: string;
+}
+
+export type Quantity = string;
+
+/**
+ * App is a Kedge file.
+ *
+ * It has one or more containers.
+ */
+export interface App {
+  annotations?: { [key: string]: unknown };
+  containers?: KedgeContainer[];
+  labels?: { [key: string]: string };
+  limits?: { [key: string]: Quantity };
+  meta?: ObjectMeta | null;
+  /**
+   * Name of the app, comments end with *\/
+   */
+  name: string;
+  policy?: "Always" | "Never";
+  port?: number | string;
+  replicas?: number | null;
+  "x-extra"?: boolean;
+}
+
+export type KedgeContainer = K8sContainer & {
+  health?: {
+    path?: string;
+  };
+};
+
+export type Volume = {
+  configMap: string;
+} | {
+  secret: string;
+} | null;
@@ -0,0 +1,514 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a single problem found while validating a Kedge file
+// against the generated definitions
+type ValidationError struct {
+	// Name of the file that was validated
+	File string `json:"file,omitempty"`
+	// Index of the YAML document in the file, starting with 0
+	Document int `json:"document"`
+	// JSON path of the value that is invalid e.g. '$.containers[0].image'
+	Path string `json:"path"`
+	// Position of the invalid value in the original file
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	// Human readable explanation of the problem
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Path, e.Message)
+}
+
+// Validator validates Kedge files, which can be either YAML or JSON,
+// against the definitions of generated OpenAPI schema
+type Validator struct {
+	defs spec.Definitions
+	// when strict is set, objects that have properties defined but say
+	// nothing about additional properties don't allow unknown fields, this
+	// is the same as 'openapi2jsonschema --strict'
+	strict bool
+	// compiled patterns are cached since the same schema is used for
+	// many values
+	patterns map[string]*regexp.Regexp
+}
+
+// Returns a validator that validates against given definitions
+func NewValidator(defs spec.Definitions, strict bool) *Validator {
+	return &Validator{
+		defs:     defs,
+		strict:   strict,
+		patterns: make(map[string]*regexp.Regexp),
+	}
+}
+
+// Reads the given file and validates every YAML document in it against
+// definition with the key root
+func (v *Validator) ValidateFile(root, filename string) ([]ValidationError, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file %q", filename)
+	}
+	defer f.Close()
+	return v.ValidateReader(root, filename, f)
+}
+
+// Validates every YAML document read from r against definition with the
+// key root, name is used in the errors to identify the input
+func (v *Validator) ValidateReader(root, name string, r io.Reader) ([]ValidationError, error) {
+	if _, ok := v.defs[root]; !ok {
+		return nil, fmt.Errorf("definition %q not found", root)
+	}
+
+	var errs []ValidationError
+	dec := yaml.NewDecoder(r)
+	for i := 0; ; i++ {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse %q", name)
+		}
+		for _, e := range v.ValidateNode(root, &doc) {
+			e.File = name
+			e.Document = i
+			errs = append(errs, e)
+		}
+	}
+	return errs, nil
+}
+
+// Validates a single parsed YAML document against definition with the
+// key root
+func (v *Validator) ValidateNode(root string, node *yaml.Node) []ValidationError {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	return v.validate(refSchema(root), node, "$")
+}
+
+// Validates a value found at path against the schema and returns all the
+// problems found in the value and the values nested inside of it
+func (v *Validator) validate(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if ref := s.Ref.String(); ref != "" {
+		key := RefKey(s.Ref)
+		def, ok := v.defs[key]
+		if !ok {
+			return []ValidationError{newValidationError(node, path, "unresolved reference %q", ref)}
+		}
+		return v.validate(def, node, path)
+	}
+
+	var errs []ValidationError
+	for _, sub := range s.AllOf {
+		errs = append(errs, v.validate(sub, node, path)...)
+	}
+	if len(s.AnyOf) > 0 && !v.matchesAny(s.AnyOf, node, path) {
+		errs = append(errs, newValidationError(node, path, "does not match any of the allowed schemas"))
+	}
+	if len(s.OneOf) > 0 {
+		errs = append(errs, v.validateOneOf(s, node, path)...)
+	}
+	if s.Not != nil && len(v.validate(*s.Not, node, path)) == 0 {
+		errs = append(errs, newValidationError(node, path, "must not match the schema"))
+	}
+
+	actual := nodeType(node)
+	if len(s.Type) > 0 && !typeMatches(s, actual) {
+		return append(errs, newValidationError(node, path, "expected %s, got %s", strings.Join(s.Type, " or "), actual))
+	}
+
+	if len(s.Enum) > 0 {
+		errs = append(errs, v.validateEnum(s, node, path)...)
+	}
+
+	switch actual {
+	case "object":
+		errs = append(errs, v.validateObject(s, node, path)...)
+	case "array":
+		errs = append(errs, v.validateArray(s, node, path)...)
+	case "string":
+		errs = append(errs, v.validateString(s, node, path)...)
+	case "integer", "number":
+		errs = append(errs, v.validateNumber(s, node, path)...)
+	}
+	return errs
+}
+
+// Returns true if the value matches at least one of the schemas
+func (v *Validator) matchesAny(schemas []spec.Schema, node *yaml.Node, path string) bool {
+	for _, sub := range schemas {
+		if len(v.validate(sub, node, path)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Validator) validateOneOf(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var matched int
+	var branchErrs []ValidationError
+	for _, sub := range s.OneOf {
+		errs := v.validate(sub, node, path)
+		if len(errs) == 0 {
+			matched++
+			continue
+		}
+		// branches like the ones of 'io.kedge.App' have their own constraints
+		// which decide if the branch applies at all, e.g. 'controller: job',
+		// errors of branches that don't apply are only noise
+		if len(v.validate(shallowSchema(sub), node, path)) == 0 {
+			branchErrs = append(branchErrs, errs...)
+		}
+	}
+
+	switch matched {
+	case 0:
+		if len(branchErrs) > 0 {
+			return branchErrs
+		}
+		return []ValidationError{newValidationError(node, path, "does not match any of the allowed schemas")}
+	case 1:
+		return nil
+	default:
+		return []ValidationError{newValidationError(node, path, "matches %d of the schemas, but must match exactly one", matched)}
+	}
+}
+
+// Returns copy of the schema without the schemas it refers to or combines,
+// so only the constraints defined in the schema itself are left
+func shallowSchema(s spec.Schema) spec.Schema {
+	s.Ref = spec.Ref{}
+	s.AllOf = nil
+	s.AnyOf = nil
+	s.OneOf = nil
+	s.Not = nil
+	return s
+}
+
+func (v *Validator) validateEnum(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return []ValidationError{newValidationError(node, path, "could not decode value: %v", err)}
+	}
+	for _, e := range s.Enum {
+		if valuesEqual(e, value) {
+			return nil
+		}
+	}
+	return []ValidationError{newValidationError(node, path, "value %v is not one of %v", value, s.Enum)}
+}
+
+func (v *Validator) validateObject(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var errs []ValidationError
+
+	fields := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		fields[key.Value] = value
+		fieldPath := joinPath(path, key.Value)
+
+		if sub, ok := s.Properties[key.Value]; ok {
+			errs = append(errs, v.validate(sub, value, fieldPath)...)
+			continue
+		}
+
+		matchedPattern := false
+		for _, pattern := range sortedSchemaKeys(s.PatternProperties) {
+			sub := s.PatternProperties[pattern]
+			re, err := v.compile(pattern)
+			if err != nil {
+				errs = append(errs, newValidationError(key, fieldPath, "invalid pattern %q in schema: %v", pattern, err))
+				continue
+			}
+			if re.MatchString(key.Value) {
+				matchedPattern = true
+				errs = append(errs, v.validate(sub, value, fieldPath)...)
+			}
+		}
+		if matchedPattern {
+			continue
+		}
+
+		switch {
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			errs = append(errs, v.validate(*s.AdditionalProperties.Schema, value, fieldPath)...)
+		case s.AdditionalProperties != nil && !s.AdditionalProperties.Allows,
+			s.AdditionalProperties == nil && v.strict && len(s.Properties) > 0:
+			errs = append(errs, newValidationError(key, fieldPath, "unknown field %q", key.Value))
+		}
+	}
+
+	for _, r := range s.Required {
+		if _, ok := fields[r]; !ok {
+			errs = append(errs, newValidationError(node, path, "missing required field %q", r))
+		}
+	}
+
+	count := int64(len(fields))
+	if s.MinProperties != nil && count < *s.MinProperties {
+		errs = append(errs, newValidationError(node, path, "must have at least %d fields", *s.MinProperties))
+	}
+	if s.MaxProperties != nil && count > *s.MaxProperties {
+		errs = append(errs, newValidationError(node, path, "must have at most %d fields", *s.MaxProperties))
+	}
+	return errs
+}
+
+func (v *Validator) validateArray(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var errs []ValidationError
+	for i, item := range node.Content {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if s.Items == nil {
+			break
+		}
+		if s.Items.Schema != nil {
+			errs = append(errs, v.validate(*s.Items.Schema, item, itemPath)...)
+		} else if i < len(s.Items.Schemas) {
+			errs = append(errs, v.validate(s.Items.Schemas[i], item, itemPath)...)
+		}
+	}
+
+	count := int64(len(node.Content))
+	if s.MinItems != nil && count < *s.MinItems {
+		errs = append(errs, newValidationError(node, path, "must have at least %d items", *s.MinItems))
+	}
+	if s.MaxItems != nil && count > *s.MaxItems {
+		errs = append(errs, newValidationError(node, path, "must have at most %d items", *s.MaxItems))
+	}
+	if s.UniqueItems {
+		var seen []interface{}
+		for i, item := range node.Content {
+			var value interface{}
+			if err := item.Decode(&value); err != nil {
+				continue
+			}
+			for _, other := range seen {
+				if reflect.DeepEqual(value, other) {
+					errs = append(errs, newValidationError(item, fmt.Sprintf("%s[%d]", path, i), "duplicate item"))
+					break
+				}
+			}
+			seen = append(seen, value)
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateString(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var errs []ValidationError
+	length := int64(len([]rune(node.Value)))
+	if s.MinLength != nil && length < *s.MinLength {
+		errs = append(errs, newValidationError(node, path, "must be at least %d characters long", *s.MinLength))
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		errs = append(errs, newValidationError(node, path, "must be at most %d characters long", *s.MaxLength))
+	}
+	if s.Pattern != "" {
+		re, err := v.compile(s.Pattern)
+		if err != nil {
+			errs = append(errs, newValidationError(node, path, "invalid pattern %q in schema: %v", s.Pattern, err))
+		} else if !re.MatchString(node.Value) {
+			errs = append(errs, newValidationError(node, path, "%q does not match pattern %q", node.Value, s.Pattern))
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateNumber(s spec.Schema, node *yaml.Node, path string) []ValidationError {
+	var value float64
+	if err := node.Decode(&value); err != nil {
+		return []ValidationError{newValidationError(node, path, "could not decode number: %v", err)}
+	}
+
+	var errs []ValidationError
+	if s.Minimum != nil && (value < *s.Minimum || s.ExclusiveMinimum && value == *s.Minimum) {
+		errs = append(errs, newValidationError(node, path, "must be greater than %s%v", orEqual(s.ExclusiveMinimum), *s.Minimum))
+	}
+	if s.Maximum != nil && (value > *s.Maximum || s.ExclusiveMaximum && value == *s.Maximum) {
+		errs = append(errs, newValidationError(node, path, "must be less than %s%v", orEqual(s.ExclusiveMaximum), *s.Maximum))
+	}
+	if s.MultipleOf != nil && *s.MultipleOf != 0 {
+		if q := value / *s.MultipleOf; q != math.Trunc(q) {
+			errs = append(errs, newValidationError(node, path, "must be a multiple of %v", *s.MultipleOf))
+		}
+	}
+	return errs
+}
+
+func (v *Validator) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := v.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns[pattern] = re
+	return re, nil
+}
+
+// Returns JSON schema type of the YAML value
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// Checks if the actual type of value is allowed by the schema, integers
+// are numbers too and Kubernetes has 'int-or-string' format for fields
+// like ports which are strings in the schema but can be given as integers
+func typeMatches(s spec.Schema, actual string) bool {
+	if s.Type.Contains(actual) {
+		return true
+	}
+	if actual == "integer" {
+		return s.Type.Contains("number") || s.Format == "int-or-string"
+	}
+	return false
+}
+
+// Compares enum value from the schema, which is decoded from JSON, with
+// the value decoded from YAML, numbers can have different Go types
+func valuesEqual(expected, actual interface{}) bool {
+	e, ok1 := toFloat(expected)
+	a, ok2 := toFloat(actual)
+	if ok1 && ok2 {
+		return e == a
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func orEqual(exclusive bool) string {
+	if exclusive {
+		return ""
+	}
+	return "or equal to "
+}
+
+// Appends the field name to the JSON path, names that are not plain
+// identifiers are quoted
+func joinPath(path, field string) string {
+	if isIdentifier(field) {
+		return path + "." + field
+	}
+	return path + "[" + strconv.Quote(field) + "]"
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Returns schema that is just a reference to definition with the given key
+func refSchema(key string) spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Ref: spec.MustCreateRef(definitionsRefPrefix + key)}}
+}
+
+// Returns the validation error as a diagnostic
+func (e ValidationError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Rule:     RuleValidation,
+		Severity: SeverityError,
+		Message:  e.Path + ": " + e.Message,
+		File:     e.File,
+		Line:     e.Line,
+		Column:   e.Column,
+	}
+}
+
+func newValidationError(node *yaml.Node, path, format string, args ...interface{}) ValidationError {
+	return ValidationError{
+		Path:    path,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Writes validation errors in human readable form, one per line
+func WriteValidationErrorsText(w io.Writer, errs []ValidationError) error {
+	var b bytes.Buffer
+	for _, e := range errs {
+		fmt.Fprintln(&b, e.Error())
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
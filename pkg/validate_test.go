@@ -0,0 +1,192 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testValidateDefinitions = `{
+	"io.kedge.App": {
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string", "pattern": "^[a-z]+$", "maxLength": 8},
+			"replicas": {"type": "integer", "minimum": 1},
+			"ratio": {"type": "number", "maximum": 1},
+			"port": {"type": "string", "format": "int-or-string"},
+			"policy": {"type": "string", "enum": ["Always", "Never"]},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"containers": {"type": "array", "minItems": 1, "items": {"$ref": "#/definitions/io.kedge.Container"}},
+			"volume": {"oneOf": [
+				{"required": ["configMap"], "properties": {"configMap": {"type": "string"}}},
+				{"required": ["secret"], "properties": {"secret": {"type": "string"}}}
+			]}
+		}
+	},
+	"io.kedge.Container": {
+		"type": "object",
+		"required": ["image"],
+		"properties": {"image": {"type": "string"}},
+		"additionalProperties": false
+	}
+}`
+
+func TestValidator(t *testing.T) {
+	defs := testDefinitions(t, testValidateDefinitions)
+	tests := []struct {
+		name   string
+		strict bool
+		doc    string
+		// '<path>: <message>' of the errors
+		expected []string
+	}{
+		{
+			"valid",
+			false,
+			`
+name: web
+replicas: 2
+ratio: 0.5
+port: 8080
+policy: Always
+labels:
+  app: web
+containers:
+- image: nginx
+volume:
+  configMap: web
+`,
+			nil,
+		},
+		{
+			"integer is a number",
+			false,
+			"name: web\nratio: 1\n",
+			nil,
+		},
+		{
+			"missing required field",
+			false,
+			"replicas: 1\n",
+			[]string{`$: missing required field "name"`},
+		},
+		{
+			"types",
+			false,
+			"name: 1\nreplicas: 1.5\nlabels:\n  app: 1\n",
+			[]string{
+				"$.name: expected string, got integer",
+				"$.replicas: expected integer, got number",
+				"$.labels.app: expected string, got integer",
+			},
+		},
+		{
+			"limits",
+			false,
+			"name: Web\nreplicas: 0\nratio: 2\n",
+			[]string{
+				`$.name: "Web" does not match pattern "^[a-z]+$"`,
+				"$.replicas: must be greater than or equal to 1",
+				"$.ratio: must be less than or equal to 1",
+			},
+		},
+		{
+			"length and enum",
+			false,
+			"name: abcdefghi\npolicy: Sometimes\n",
+			[]string{
+				"$.name: must be at most 8 characters long",
+				"$.policy: value Sometimes is not one of [Always Never]",
+			},
+		},
+		{
+			"items",
+			false,
+			"name: web\ncontainers:\n- name: nginx\n",
+			[]string{
+				`$.containers[0].name: unknown field "name"`,
+				`$.containers[0]: missing required field "image"`,
+			},
+		},
+		{
+			"min items",
+			false,
+			"name: web\ncontainers: []\n",
+			[]string{"$.containers: must have at least 1 items"},
+		},
+		{
+			"oneOf",
+			false,
+			"name: web\nvolume:\n  configMap: web\n  secret: web\n",
+			[]string{"$.volume: matches 2 of the schemas, but must match exactly one"},
+		},
+		{
+			"oneOf none",
+			false,
+			"name: web\nvolume: {}\n",
+			[]string{"$.volume: does not match any of the allowed schemas"},
+		},
+		{
+			"unknown field",
+			false,
+			"name: web\nimage: nginx\n",
+			nil,
+		},
+		{
+			"unknown field strict",
+			true,
+			"name: web\nimage: nginx\n",
+			[]string{`$.image: unknown field "image"`},
+		},
+	}
+
+	for _, test := range tests {
+		errs, err := NewValidator(defs, test.strict).ValidateReader("io.kedge.App", "app.yaml", strings.NewReader(test.doc))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		var actual []string
+		for _, e := range errs {
+			actual = append(actual, e.Path+": "+e.Message)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected errors %q, got %q", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestValidatorPosition(t *testing.T) {
+	defs := testDefinitions(t, testValidateDefinitions)
+	errs, err := NewValidator(defs, false).ValidateReader("io.kedge.App", "app.yaml", strings.NewReader("name: web\n---\nname: web\nreplicas: zero\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	if e := errs[0]; e.File != "app.yaml" || e.Document != 1 || e.Line != 4 || e.Column != 11 {
+		t.Errorf("expected error in second document at 4:11, got %+v", e)
+	}
+
+	if _, err := NewValidator(defs, false).ValidateReader("io.kedge.Missing", "app.yaml", strings.NewReader("{}")); err == nil {
+		t.Errorf("expected error validating against missing definition")
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// How often files are checked for changes when no interval is given
+const DefaultWatchInterval = time.Second
+
+// Watcher regenerates the schema whenever the Kedge sources, the config file
+// or the upstream schemas change. Changes are found by polling, which works
+// the same everywhere, also with editors that replace files when saving.
+// Upstream schemas are big, so they are kept parsed in memory and parsed
+// again only when they change.
+type Watcher struct {
+	// Load returns the config, it is called again when the config file
+	// changes, the config needs an output file or definition directory
+	Load func() (*Config, error)
+	// Config file to watch, blank if there is none
+	ConfigFile string
+	// How often files are checked, DefaultWatchInterval if not given
+	Interval time.Duration
+	// Called with the result of every generation
+	OnResult func(WatchResult)
+
+	cfg            *Config
+	configState    map[string]fileState
+	upstream       *spec.Swagger
+	upstreamState  map[string]fileState
+	lastDefinition spec.Definitions
+}
+
+// WatchResult is what happened in one generation of the Watcher
+type WatchResult struct {
+	Time time.Time
+	// File the schema was written to, or the definition directory if there
+	// is no output file
+	File        string
+	Diagnostics []Diagnostic
+	// Changes since the previous generation, nil for the first one
+	Changes *DefinitionChanges
+	Diff    *SchemaDiff
+	// Error that stopped generation, the previous output is left as it is
+	Err error
+}
+
+// Generates the schema and then again every time any of the inputs change,
+// until stop is closed
+func (w *Watcher) Run(stop <-chan struct{}) error {
+	pollInputs(w.Interval, stop, w.inputs, func() {
+		w.OnResult(w.generate())
+	})
+	return nil
+}
+
+// Calls changed and then again every time any of the inputs change, the
+// inputs are checked every interval until stop is closed
+func pollInputs(interval time.Duration, stop <-chan struct{}, inputs func() []string, changed func()) {
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var states map[string]fileState
+	for {
+		current := statFiles(inputs())
+		if states == nil || !sameStates(states, current) {
+			states = current
+			changed()
+			// the inputs are different when config was loaded again
+			states = mergeStates(states, statFiles(inputs()))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns all the files generation depends on
+func (w *Watcher) inputs() []string {
+	return generationInputs(w.ConfigFile, w.cfg)
+}
+
+// Returns the config file and the files generation with the config depends
+// on, only the config file if config is not loaded yet
+func generationInputs(configFile string, cfg *Config) []string {
+	var files []string
+	if configFile != "" {
+		files = append(files, configFile)
+	}
+	if cfg == nil {
+		return files
+	}
+	for _, src := range cfg.Kedge.Sources {
+		// directories are expanded every time, so that new files are seen
+		if expanded, err := ExpandSources([]string{src}); err == nil {
+			files = append(files, expanded...)
+		} else {
+			files = append(files, src)
+		}
+	}
+	files = append(files, cfg.Upstream.Kubernetes)
+	if cfg.Upstream.OpenShift != "" {
+		files = append(files, cfg.Upstream.OpenShift)
+	}
+	for _, r := range cfg.Upstream.Releases {
+		files = append(files, r.Schema)
+	}
+	return files
+}
+
+func (w *Watcher) upstreamFiles() []string {
+	files := []string{w.cfg.Upstream.Kubernetes}
+	if w.cfg.Upstream.OpenShift != "" {
+		files = append(files, w.cfg.Upstream.OpenShift)
+	}
+	return files
+}
+
+// Generates once, loading config and parsing upstream schemas again only
+// if they have changed
+func (w *Watcher) generate() WatchResult {
+	res := WatchResult{Time: time.Now()}
+
+	if w.ConfigFile != "" || w.cfg == nil {
+		state := statFiles([]string{w.ConfigFile})
+		if w.cfg == nil || !sameStates(state, w.configState) {
+			cfg, err := w.Load()
+			if err != nil {
+				res.Err = err
+				return res
+			}
+			w.cfg, w.configState = cfg, state
+		}
+	}
+	res.File = w.cfg.Output.File
+	if res.File == "" {
+		res.File = w.cfg.Output.OutDir
+	}
+
+	opts := w.cfg.GeneratorOptions()
+	opts.Diagnostics = func(d Diagnostic) {
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+	g, err := NewGenerator(opts)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	state := statFiles(w.upstreamFiles())
+	if w.upstream == nil || !sameStates(state, w.upstreamState) {
+		upstream, err := g.ParseUpstream()
+		if err != nil {
+			w.upstream = nil
+			res.Err = err
+			return res
+		}
+		w.upstream, w.upstreamState = upstream, state
+	}
+
+	api, err := g.GenerateFrom(w.upstream)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if err := writeGenerated(api, w.cfg.Output); err != nil {
+		res.Err = err
+		return res
+	}
+
+	if w.lastDefinition != nil {
+		res.Changes = ChangedDefinitions(w.lastDefinition, api.Definitions)
+		res.Diff = DiffDefinitions(w.lastDefinition, api.Definitions)
+	}
+	w.lastDefinition = api.Definitions
+	return res
+}
+
+// Writes short human readable summary of the watch result
+func WriteWatchResultText(w io.Writer, r WatchResult) error {
+	var b bytes.Buffer
+	stamp := r.Time.Format("15:04:05")
+	for _, d := range r.Diagnostics {
+		fmt.Fprintf(&b, "%s\n", d)
+	}
+	switch {
+	case r.Err != nil:
+		if d, ok := ErrorDiagnostic(r.Err); ok {
+			fmt.Fprintf(&b, "[%s] %s\n", stamp, d)
+		} else {
+			fmt.Fprintf(&b, "[%s] error: %v\n", stamp, r.Err)
+		}
+	case r.Changes == nil:
+		fmt.Fprintf(&b, "[%s] wrote %s\n", stamp, r.File)
+	case r.Changes.Empty():
+		fmt.Fprintf(&b, "[%s] wrote %s, no definitions changed\n", stamp, r.File)
+	default:
+		fmt.Fprintf(&b, "[%s] wrote %s, %d changed, %d added, %d removed definitions, %d breaking changes\n",
+			stamp, r.File, len(r.Changes.Changed), len(r.Changes.Added), len(r.Changes.Removed), len(r.Diff.Breaking))
+		for _, l := range []struct {
+			name string
+			keys []string
+		}{
+			{"changed", r.Changes.Changed},
+			{"added", r.Changes.Added},
+			{"removed", r.Changes.Removed},
+		} {
+			if len(l.keys) > 0 {
+				fmt.Fprintf(&b, "  %s: %s\n", l.name, strings.Join(l.keys, ", "))
+			}
+		}
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// fileState is what is compared to find if a file has changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Returns the states of files, the ones that don't exist are left out so
+// that creating them is a change too
+func statFiles(files []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			states[f] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return states
+}
+
+func sameStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if o, ok := b[k]; !ok || !o.modTime.Equal(v.modTime) || o.size != v.size {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the states of b with the states of a for the files in both, so
+// that changes made to files in a after it was taken are still found
+func mergeStates(a, b map[string]fileState) map[string]fileState {
+	merged := make(map[string]fileState)
+	for k, v := range b {
+		if o, ok := a[k]; ok {
+			v = o
+		}
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2017 The Kedge Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcherRegenerates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemagen-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := testConfig(t, dir)
+	cfg.Output.OutDir = filepath.Join(dir, "definitions")
+	cfg.Output.Bundle = true
+	results := make(chan WatchResult)
+	w := &Watcher{
+		Load:     func() (*Config, error) { return cfg, nil },
+		Interval: 10 * time.Millisecond,
+		OnResult: func(r WatchResult) { results <- r },
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		// the watcher may be waiting to give a result
+		for {
+			select {
+			case <-results:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	next := func() WatchResult {
+		select {
+		case r := <-results:
+			if r.Err != nil {
+				t.Fatalf("could not generate: %v", r.Err)
+			}
+			return r
+		case <-time.After(10 * time.Second):
+			t.Fatal("schema was not generated")
+		}
+		return WatchResult{}
+	}
+
+	r := next()
+	if r.File != cfg.Output.OutDir {
+		t.Errorf("expected result to be written to %q, got %q", cfg.Output.OutDir, r.File)
+	}
+	for _, name := range []string{SplitIndexFile, SplitBundleFile, "deploymentspecmod.json"} {
+		if _, err := os.Stat(filepath.Join(cfg.Output.OutDir, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	changed := strings.Replace(testKedgeSpec, "	// +optional\n	Replicas", "	// +optional\n	Paused bool `json:\"paused,omitempty\"`\n	// +optional\n	Replicas", 1)
+	if err := ioutil.WriteFile(cfg.Kedge.Sources[0], []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r = next()
+	if r.Changes == nil || strings.Join(r.Changes.Changed, ",") != "io.kedge.DeploymentSpecMod" {
+		t.Errorf("expected only io.kedge.DeploymentSpecMod to change, got %+v", r.Changes)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(cfg.Output.OutDir, "deploymentspecmod.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"paused"`) {
+		t.Errorf("expected the new field to be written, got\n%s", content)
+	}
+}